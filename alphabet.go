@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	callbackIndexV1  = "index:v1"  // index:v1:<fidel|latin> switches the letter keyboard
+	callbackLetterV1 = "letter:v1" // letter:v1:<letter> lists songs for a letter
+)
+
+const (
+	indexFidel = "fidel"
+	indexLatin = "latin"
+)
+
+// fidelBases are the base (first order) letters offered in the Ge'ez index,
+// in traditional ሀሁሂ order.
+var fidelBases = []string{
+	"ሀ", "ለ", "ሐ", "መ", "ሠ", "ረ", "ሰ", "ሸ", "ቀ", "በ", "ቨ", "ተ",
+	"ቸ", "ኀ", "ነ", "ኘ", "አ", "ከ", "ኸ", "ወ", "ዐ", "ዘ", "ዠ", "የ",
+	"ደ", "ጀ", "ገ", "ጠ", "ጨ", "ጰ", "ጸ", "ፀ", "ፈ", "ፐ",
+}
+
+const (
+	ethiopicFirst = 0x1200
+	ethiopicLast  = 0x135A // last syllable in the Ethiopic block
+)
+
+// isFidel reports whether r is an Ethiopic syllable.
+func isFidel(r rune) bool {
+	return r >= ethiopicFirst && r <= ethiopicLast
+}
+
+// fidelBase returns the first-order form of an Ethiopic syllable. The Unicode
+// block lays each consonant out as a row of eight code points: the seven
+// orders followed by the labialized form, so the base is the start of the row.
+func fidelBase(r rune) rune {
+	return r - (r-ethiopicFirst)%8
+}
+
+// fidelFamily returns every syllable sharing r's consonant.
+func fidelFamily(r rune) []string {
+	base := fidelBase(r)
+	family := make([]string, 0, 8)
+	for i := rune(0); i < 8; i++ {
+		family = append(family, string(base+i))
+	}
+	return family
+}
+
+// letterKeyboard returns the inline keyboard for the Ge'ez or Latin index.
+func letterKeyboard(index string) tgbotapi.InlineKeyboardMarkup {
+	letters, perRow, toggle := fidelBases, 6, tgbotapi.NewInlineKeyboardButtonData("A–Z", callbackIndexV1+":"+indexLatin)
+	if index == indexLatin {
+		letters = nil
+		for c := 'A'; c <= 'Z'; c++ {
+			letters = append(letters, string(c))
+		}
+		perRow, toggle = 7, tgbotapi.NewInlineKeyboardButtonData("ሀ–ፐ", callbackIndexV1+":"+indexFidel)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, letter := range letters {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(letter, callbackLetterV1+":"+letter))
+		if len(row) == perRow {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	row = append(row, toggle)
+	rows = append(rows, row)
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendLetterIndex sends the Ge'ez letter keyboard used by "View All Songs".
+func sendLetterIndex(bot *tgbotapi.BotAPI, chatID int64) {
+	msg := tgbotapi.NewMessage(chatID,
+		"Please select a letter to see songs starting with it, or type a letter (ሀ–ፐ or A-Z):")
+	msg.ReplyMarkup = letterKeyboard(indexFidel)
+	bot.Send(msg)
+}
+
+func handleIndexCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, index string) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID,
+		letterKeyboard(index))
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Failed to switch letter index: %v", err)
+	}
+}
+
+func handleLetterCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, letter string) {
+	showSongsByLetter(bot, query.Message.Chat.ID, store, letter)
+}
+
+// parseLetter validates a typed index letter: a single Latin letter (returned
+// upper-cased) or a single Ethiopic syllable.
+func parseLetter(text string) (string, bool) {
+	if utf8.RuneCountInString(text) != 1 {
+		return "", false
+	}
+	r, _ := utf8.DecodeRuneInString(text)
+	if isFidel(r) {
+		return text, true
+	}
+	letter := strings.ToUpper(text)
+	if letter < "A" || letter > "Z" {
+		return "", false
+	}
+	return letter, true
+}
+
+// songsByLetter returns songs starting with letter. An Ethiopic letter matches
+// its whole fidel family, so ለ also finds titles starting with ሉ, ሊ, ላ, ...
+func songsByLetter(store SongStore, letter string) ([]Song, error) {
+	r, _ := utf8.DecodeRuneInString(letter)
+	if isFidel(r) {
+		return store.FindByPrefixes(context.TODO(), fidelFamily(r))
+	}
+	return store.FindByPrefix(context.TODO(), letter)
+}
+
+func showSongsByLetter(bot *tgbotapi.BotAPI, chatID int64, store SongStore, letter string) {
+	songs, err := songsByLetter(store, letter)
+	if err != nil {
+		log.Printf("Failed to query songs: %v", err)
+		return
+	}
+
+	r, _ := utf8.DecodeRuneInString(letter)
+	if isFidel(r) {
+		letter = string(fidelBase(r))
+	}
+	if len(songs) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("No songs found starting with %s.", letter)))
+		return
+	}
+	sendSongList(bot, chatID, "Select a song to get the lyrics:", letterList(letter), songs)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// AttachmentKind says how an attachment is sent to Telegram.
+type AttachmentKind string
+
+const (
+	AttachmentPhoto    AttachmentKind = "photo"
+	AttachmentDocument AttachmentKind = "document"
+)
+
+// Attachment is one page of a song: a photo kept in the image store, or a
+// document such as a PDF score kept on Telegram.
+type Attachment struct {
+	Kind AttachmentKind `bson:"kind" json:"kind"`
+	// Ref is an ImageStore reference for photos and the Telegram file_id
+	// for documents.
+	Ref string `bson:"ref" json:"ref"`
+	// FileID caches the Telegram file_id of a photo once it has been sent.
+	FileID string `bson:"file_id,omitempty" json:"file_id,omitempty"`
+	// Name is the file name of a document.
+	Name string `bson:"name,omitempty" json:"name,omitempty"`
+}
+
+// maxMediaGroup is the most items Telegram accepts in one media group.
+const maxMediaGroup = 10
+
+// inputMedia is one item of a sendMediaGroup request. The library only
+// defines photo and video items, and songs also have PDF documents.
+type inputMedia struct {
+	Type      string `json:"type"`
+	Media     string `json:"media"`
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+// media returns the file Telegram should send for a, preferring the cached
+// file_id of a photo when cached is true.
+func (a Attachment) media(cached bool) string {
+	if a.Kind == AttachmentDocument {
+		return a.Ref
+	}
+	if cached && a.FileID != "" {
+		return a.FileID
+	}
+	return images.URL(a.Ref)
+}
+
+// attachmentGroups splits attachments into the [start, end) ranges sent as
+// one media group. Telegram does not mix documents with photos in a group,
+// so each run of one kind is grouped, ten items at a time.
+func attachmentGroups(attachments []Attachment) [][2]int {
+	var groups [][2]int
+	start := 0
+	for i := 1; i <= len(attachments); i++ {
+		if i == len(attachments) || i-start == maxMediaGroup || attachments[i].Kind != attachments[start].Kind {
+			groups = append(groups, [2]int{start, i})
+			start = i
+		}
+	}
+	return groups
+}
+
+// sendAttachments sends a song's pages as media groups, captioning the first
+// with the song title. Like sendSongPhoto it tries cached file_ids first,
+// falls back to the stored images, and caches the file_ids Telegram returns.
+func sendAttachments(bot *tgbotapi.BotAPI, store SongStore, chatID int64, song *Song) {
+	for n, group := range attachmentGroups(song.Attachments) {
+		pages := song.Attachments[group[0]:group[1]]
+		caption := ""
+		if n == 0 {
+			caption = fmt.Sprintf("<b>%s</b>", html.EscapeString(song.Title))
+		}
+
+		sent, err := sendMediaGroup(bot, chatID, attachmentMedia(pages, caption, true))
+		if err != nil && hasCachedFileIDs(pages) {
+			log.Printf("Cached pages for %q failed, refetching: %v", song.Title, err)
+			sent, err = sendMediaGroup(bot, chatID, attachmentMedia(pages, caption, false))
+		}
+		if err != nil {
+			log.Printf("Failed to send pages of %q: %v", song.Title, err)
+			continue
+		}
+
+		for i, msg := range sent {
+			if i >= len(pages) || pages[i].Kind != AttachmentPhoto || msg.Photo == nil || len(*msg.Photo) == 0 {
+				continue
+			}
+			fileID := (*msg.Photo)[len(*msg.Photo)-1].FileID
+			if fileID == pages[i].FileID {
+				continue
+			}
+			if err := store.SetAttachmentFileID(context.TODO(), song.ID, pages[i].Ref, fileID); err != nil {
+				log.Printf("Failed to cache page file_id for %q: %v", song.Title, err)
+			}
+		}
+	}
+}
+
+func attachmentMedia(pages []Attachment, caption string, cached bool) []inputMedia {
+	items := make([]inputMedia, len(pages))
+	for i, page := range pages {
+		items[i] = inputMedia{Type: string(page.Kind), Media: page.media(cached)}
+	}
+	if caption != "" && len(items) > 0 {
+		items[0].Caption = caption
+		items[0].ParseMode = tgbotapi.ModeHTML
+	}
+	return items
+}
+
+func hasCachedFileIDs(pages []Attachment) bool {
+	for _, page := range pages {
+		if page.FileID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sendMediaGroup sends items as one album and returns the sent messages.
+// A media group needs at least two items, so a single item is sent on its
+// own. bot.Send cannot be used for groups because Telegram answers with an
+// array of messages.
+func sendMediaGroup(bot *tgbotapi.BotAPI, chatID int64, items []inputMedia) ([]tgbotapi.Message, error) {
+	if len(items) == 1 {
+		item := items[0]
+		var msg tgbotapi.Chattable
+		if item.Type == string(AttachmentDocument) {
+			doc := tgbotapi.NewDocumentShare(chatID, item.Media)
+			doc.Caption, doc.ParseMode = item.Caption, item.ParseMode
+			msg = doc
+		} else {
+			photo := tgbotapi.NewPhotoShare(chatID, item.Media)
+			photo.Caption, photo.ParseMode = item.Caption, item.ParseMode
+			msg = photo
+		}
+		sent, err := bot.Send(msg)
+		if err != nil {
+			return nil, err
+		}
+		return []tgbotapi.Message{sent}, nil
+	}
+
+	media, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	v := url.Values{}
+	v.Add("chat_id", strconv.FormatInt(chatID, 10))
+	v.Add("media", string(media))
+
+	resp, err := bot.MakeRequest("sendMediaGroup", v)
+	if err != nil {
+		return nil, err
+	}
+	var sent []tgbotapi.Message
+	if err := json.Unmarshal(resp.Result, &sent); err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
+// addPageCommand starts collecting pages for a song: every photo or PDF the
+// admin sends next is appended to it until /done.
+func addPageCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	title := strings.TrimSpace(message.CommandArguments())
+	if title == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Usage: /addpage <song title>"))
+		return
+	}
+	song, exists := findSong(store, title)
+	if !exists {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song not found."))
+		return
+	}
+
+	text := fmt.Sprintf("Send the photos or PDF pages for %q, in order. Send /done when you are finished.", song.Title)
+	if !message.Chat.IsPrivate() {
+		text += " In this group, send each page as a reply to one of my messages."
+	}
+	sendPrompt(bot, message, UserState{Stage: "awaiting_pages", Title: song.Title}, replyTo(message, text))
+}
+
+// addPage appends the photo or PDF in message to the song being collected.
+// Its replies become the prompt, so in groups the next page can be sent as
+// a reply to any of them.
+func addPage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore, state UserState) {
+	prompt := func(text string) {
+		sendPrompt(bot, message, state, replyTo(message, text))
+	}
+
+	var page Attachment
+	switch {
+	case message.Photo != nil:
+		photo := (*message.Photo)[len(*message.Photo)-1]
+		ref, err := images.Put(context.TODO(), telegramImage(bot, photo))
+		if err != nil {
+			log.Printf("Failed to store page image: %v", err)
+			prompt(imageErrorText(err))
+			return
+		}
+		page = Attachment{Kind: AttachmentPhoto, Ref: ref, FileID: photo.FileID}
+	case message.Document != nil && message.Document.MimeType == "application/pdf":
+		page = Attachment{Kind: AttachmentDocument, Ref: message.Document.FileID, Name: message.Document.FileName}
+	default:
+		prompt("Please send a photo or a PDF document, or /done to finish.")
+		return
+	}
+
+	song, exists := findSong(store, state.Title)
+	if !exists {
+		clearState(stateKey(message))
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song not found."))
+		return
+	}
+	song.Attachments = append(song.Attachments, page)
+	if err := store.Update(context.TODO(), song); err != nil {
+		log.Printf("Failed to add page to %q: %v", song.Title, err)
+		prompt("Failed to add the page.")
+		return
+	}
+	prompt(fmt.Sprintf("Page %d added to %q. Send another, or /done when you are finished.",
+		len(song.Attachments), song.Title))
+}
+
+// pagesCommand lists a song's pages with their numbers.
+func pagesCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	title := strings.TrimSpace(message.CommandArguments())
+	if title == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Usage: /pages <song title>"))
+		return
+	}
+	song, exists := findSong(store, title)
+	if !exists {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song not found."))
+		return
+	}
+	if len(song.Attachments) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf(
+			"%q has no pages. Use /addpage %s to add some.", song.Title, song.Title)))
+		return
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Pages of %q:\n", song.Title)
+	for i, page := range song.Attachments {
+		if page.Kind == AttachmentDocument {
+			fmt.Fprintf(&b, "%d. PDF %s\n", i+1, page.Name)
+		} else {
+			fmt.Fprintf(&b, "%d. Photo\n", i+1)
+		}
+	}
+	b.WriteString("\n/movepage <title>|<from>|<to> moves a page\n/removepage <title>|<page> removes one")
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, b.String()))
+}
+
+// pageArgs parses "<title>|<n>|..." arguments, returning the song and the
+// zero-based page indexes. Only the last count "|" separate page numbers, so
+// titles may contain "|". It replies with usage and returns false on error.
+func pageArgs(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore, usage string, count int) (*Song, []int, bool) {
+	args := strings.Split(message.CommandArguments(), "|")
+	if len(args) < count+1 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, usage))
+		return nil, nil, false
+	}
+	title := strings.Join(args[:len(args)-count], "|")
+	args = args[len(args)-count:]
+	song, exists := findSong(store, strings.TrimSpace(title))
+	if !exists {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song not found."))
+		return nil, nil, false
+	}
+
+	pages := make([]int, count)
+	for i, arg := range args {
+		n, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || n < 1 || n > len(song.Attachments) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf(
+				"%q has %d pages; %q is not one of them.", song.Title, len(song.Attachments), strings.TrimSpace(arg))))
+			return nil, nil, false
+		}
+		pages[i] = n - 1
+	}
+	return song, pages, true
+}
+
+func movePageCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	song, pages, ok := pageArgs(bot, message, store, "Usage: /movepage <title>|<from>|<to>", 2)
+	if !ok {
+		return
+	}
+
+	from, to := pages[0], pages[1]
+	page := song.Attachments[from]
+	rest := append(song.Attachments[:from:from], song.Attachments[from+1:]...)
+	song.Attachments = append(rest[:to:to], append([]Attachment{page}, rest[to:]...)...)
+
+	if err := store.Update(context.TODO(), song); err != nil {
+		log.Printf("Failed to move page of %q: %v", song.Title, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to move the page."))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Moved page %d to %d.", from+1, to+1)))
+}
+
+func removePageCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	song, pages, ok := pageArgs(bot, message, store, "Usage: /removepage <title>|<page>", 1)
+	if !ok {
+		return
+	}
+
+	n := pages[0]
+	page := song.Attachments[n]
+	song.Attachments = append(song.Attachments[:n:n], song.Attachments[n+1:]...)
+
+	if err := store.Update(context.TODO(), song); err != nil {
+		log.Printf("Failed to remove page of %q: %v", song.Title, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to remove the page."))
+		return
+	}
+	if page.Kind == AttachmentPhoto {
+		if err := images.Delete(context.TODO(), page.Ref); err != nil {
+			log.Printf("Failed to delete page image %s: %v", page.Ref, err)
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Removed page %d of %q.", n+1, song.Title)))
+}
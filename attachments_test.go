@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAttachmentGroups(t *testing.T) {
+	photo := Attachment{Kind: AttachmentPhoto}
+	doc := Attachment{Kind: AttachmentDocument}
+	var twelve []Attachment
+	for i := 0; i < 12; i++ {
+		twelve = append(twelve, photo)
+	}
+
+	tests := []struct {
+		name  string
+		pages []Attachment
+		want  [][2]int
+	}{
+		{"none", nil, nil},
+		{"one", []Attachment{photo}, [][2]int{{0, 1}}},
+		{"mixed kinds", []Attachment{photo, photo, doc, photo}, [][2]int{{0, 2}, {2, 3}, {3, 4}}},
+		{"over ten", twelve, [][2]int{{0, 10}, {10, 12}}},
+	}
+	for _, tt := range tests {
+		if got := attachmentGroups(tt.pages); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: attachmentGroups = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPageArgsTitleWithPipe(t *testing.T) {
+	bot, fake := newFakeBot(t)
+	store := NewMemorySongStore()
+	song := &Song{Title: "Alpha | Omega", Attachments: []Attachment{
+		{Kind: AttachmentPhoto, Ref: "a"}, {Kind: AttachmentPhoto, Ref: "b"}, {Kind: AttachmentPhoto, Ref: "c"},
+	}}
+	if err := store.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+
+	got, pages, ok := pageArgs(bot, textMessage(1, 1, "/movepage Alpha | Omega|3|1"), store, "usage", 2)
+	if !ok || got.ID != song.ID || !reflect.DeepEqual(pages, []int{2, 0}) {
+		t.Fatalf("pageArgs = %v, %v, %v; want the song and pages [2 0]", got, pages, ok)
+	}
+
+	if _, _, ok := pageArgs(bot, textMessage(1, 1, "/movepage Alpha | Omega|3"), store, "usage", 2); ok {
+		t.Errorf("pageArgs accepted a missing page number")
+	}
+	if texts := fake.texts(); len(texts) != 1 || texts[0] != "Song not found." {
+		t.Errorf("replies = %q, want the song reported missing", texts)
+	}
+	if _, _, ok := pageArgs(bot, textMessage(1, 1, "/movepage Alpha | Omega|3|9"), store, "usage", 2); ok {
+		t.Errorf("pageArgs accepted page 9 of 3")
+	}
+	if texts := fake.texts(); len(texts) != 1 || !strings.Contains(texts[0], `"9" is not one of them`) {
+		t.Errorf("replies = %q, want the bad page number reported", texts)
+	}
+}
+
+func TestSetAttachmentFileID(t *testing.T) {
+	store := NewMemorySongStore()
+	song := &Song{Title: "Amazing Grace", Attachments: []Attachment{
+		{Kind: AttachmentPhoto, Ref: "a"}, {Kind: AttachmentPhoto, Ref: "b"},
+	}}
+	if err := store.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+	viewed, _ := store.Get(context.Background(), song.ID)
+
+	moved := *song
+	moved.Attachments = []Attachment{song.Attachments[1], song.Attachments[0]}
+	if err := store.Update(context.Background(), &moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetAttachmentFileID(context.Background(), viewed.ID, "a", "file-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := store.Get(context.Background(), song.ID)
+	if got.Attachments[0].Ref != "b" || got.Attachments[1].FileID != "file-a" || got.Attachments[0].FileID != "" {
+		t.Errorf("attachments = %+v, want b then a with a's file_id", got.Attachments)
+	}
+	if viewed.Attachments[0].FileID != "" {
+		t.Errorf("SetAttachmentFileID changed a song returned earlier")
+	}
+}
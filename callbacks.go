@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Callback data is "<kind>:<version>:<payload>". Telegram caps callback data
+// at 64 bytes, so payloads carry short IDs rather than song titles.
+const (
+	callbackSongV1 = "song:v1"
+)
+
+type callbackHandler func(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, payload string)
+
+var callbackRoutes = map[string]callbackHandler{
+	callbackSongV1: handleSongCallback,
+	callbackPageV1: handlePageCallback,
+	callbackNoopV1: handleNoopCallback,
+
+	callbackIndexV1:  handleIndexCallback,
+	callbackLetterV1: handleLetterCallback,
+	callbackFollowV1: handleFollowCallback,
+	callbackCardV1:   handleCardCallback,
+}
+
+// songCallbackData returns the callback data that opens the given song.
+func songCallbackData(song Song) string {
+	return callbackSongV1 + ":" + song.ID
+}
+
+// songButton returns an inline button that opens song when pressed.
+func songButton(song Song) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(song.Title, songCallbackData(song))
+}
+
+// parseCallbackData splits data into its route ("<kind>:<version>") and
+// payload. ok is false when data is not in the versioned format.
+func parseCallbackData(data string) (route, payload string, ok bool) {
+	parts := strings.SplitN(data, ":", 3)
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	return parts[0] + ":" + parts[1], parts[2], true
+}
+
+func handleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, store SongStore) {
+	defer bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackQuery.ID, ""))
+
+	route, payload, ok := parseCallbackData(callbackQuery.Data)
+	if handler, found := callbackRoutes[route]; ok && found {
+		handler(bot, callbackQuery, store, payload)
+		return
+	}
+
+	// Keyboards sent before callback data was versioned carry the bare title.
+	song, exists := findSong(store, callbackQuery.Data)
+	if !exists {
+		log.Printf("Unhandled callback data %q", callbackQuery.Data)
+		bot.Send(tgbotapi.NewMessage(callbackQuery.Message.Chat.ID,
+			"Sorry, I couldn't find the lyrics for that song."))
+		return
+	}
+	sendSong(bot, store, callbackQuery.Message.Chat.ID, song)
+}
+
+func handleSongCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, id string) {
+	song, err := store.Get(context.TODO(), id)
+	if err != nil {
+		if err != ErrSongNotFound {
+			log.Printf("Failed to load song %s: %v", id, err)
+		}
+		bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Sorry, I couldn't find the lyrics for that song."))
+		return
+	}
+	sendSong(bot, store, query.Message.Chat.ID, song)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// callbackCardV1 sends a song as a lyric card image: card:v1:<song id>.
+const callbackCardV1 = "card:v1"
+
+// cardFonts holds the font lyric cards are drawn with: the first .ttf or
+// .otf file in fonts/, which must cover Ethiopic (Noto Sans Ethiopic or
+// Abyssinica SIL, for example). CARD_FONT names a font file to use instead.
+//
+//go:embed fonts
+var cardFonts embed.FS
+
+// cardBranding is printed at the foot of every card.
+const cardBranding = "Maranatha Choir"
+
+// Card layout, in pixels at 72 DPI so font sizes are pixel sizes too.
+const (
+	cardWidth     = 1080
+	cardMinHeight = 1080
+	cardPadding   = 90
+	cardTitleSize = 60
+	cardBodySize  = 42
+	cardBrandSize = 30
+	cardMaxLines  = 14
+)
+
+var (
+	cardBackground = color.RGBA{0x1e, 0x2a, 0x3a, 0xff}
+	cardAccent     = color.RGBA{0xd4, 0xaf, 0x37, 0xff}
+	cardText       = color.RGBA{0xf5, 0xf5, 0xf5, 0xff}
+	cardMuted      = color.RGBA{0x9a, 0xa8, 0xb8, 0xff}
+)
+
+// errCardGlyphs is returned when the card font has no glyphs for some of the
+// song's text, which would otherwise be drawn as empty boxes.
+var errCardGlyphs = errors.New("card font cannot draw this song")
+
+// errCardsDisabled is returned for every card when no usable card font was
+// found; main logs why at startup.
+var errCardsDisabled = errors.New("lyric cards are disabled")
+
+var (
+	cardFontOnce sync.Once
+	cardFont     *opentype.Font
+	cardFontErr  error
+)
+
+// cardFontSample is text every card font must be able to draw: Ge'ez
+// letters from the catalogue's titles, and the Latin branding.
+const cardFontSample = "ሀለሐመሠረሰሸቀበተቸኀነኘአከኸወዐዘዠየደጀገጠጨጰጸፀፈፐ፡።፣ " + cardBranding
+
+// loadCardFont parses the card font once. main calls it at startup to log
+// why cards are off when there is no usable font; the bot runs without them.
+func loadCardFont() (*opentype.Font, error) {
+	cardFontOnce.Do(func() {
+		var data []byte
+		if path := os.Getenv("CARD_FONT"); path != "" {
+			data, cardFontErr = os.ReadFile(path)
+		} else if matches, _ := fs.Glob(cardFonts, "fonts/*.[ot]tf"); len(matches) > 0 {
+			data, cardFontErr = cardFonts.ReadFile(matches[0])
+		} else {
+			cardFontErr = errors.New("no card font: add an Ethiopic font to fonts/ or set CARD_FONT")
+		}
+		if cardFontErr != nil {
+			return
+		}
+		cardFont, cardFontErr = parseCardFont(data)
+	})
+	return cardFont, cardFontErr
+}
+
+// parseCardFont parses data and checks that it can draw cardFontSample.
+func parseCardFont(data []byte) (*opentype.Font, error) {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	face, err := cardFace(f, cardBodySize)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+	if !canDraw(face, cardFontSample) {
+		return nil, errors.New("card font does not cover Ethiopic text")
+	}
+	return f, nil
+}
+
+func cardFace(f *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// cardStanza returns the lines of the first section sung in song.
+func cardStanza(song *Song) []string {
+	st := song.Structured()
+	if len(st.Order) == 0 {
+		return nil
+	}
+	return st.Sections[st.Order[0]].Lines
+}
+
+// canDraw reports whether face has a glyph for every visible rune of text.
+func canDraw(face font.Face, text string) bool {
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		if _, ok := face.GlyphAdvance(r); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapText breaks text into lines no wider than width, between words where
+// possible and inside a word only when it is wider than a whole line.
+func wrapText(face font.Face, text string, width int) []string {
+	limit := fixed.I(width)
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if font.MeasureString(face, candidate) <= limit {
+			line = candidate
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+		for font.MeasureString(face, word) > limit {
+			runes := []rune(word)
+			n := len(runes) - 1
+			for n > 1 && font.MeasureString(face, string(runes[:n])) > limit {
+				n--
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		line = word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// renderLyricCard draws a PNG card with the song title, its first stanza and
+// the choir's name. It returns errCardGlyphs when the font lacks glyphs for
+// some of the song's text, such as rare Ethiopic extensions or emoji, and
+// errCardsDisabled when there is no card font at all.
+func renderLyricCard(song *Song) ([]byte, error) {
+	f, err := loadCardFont()
+	if err != nil {
+		return nil, errCardsDisabled
+	}
+	titleFace, err := cardFace(f, cardTitleSize)
+	if err != nil {
+		return nil, err
+	}
+	bodyFace, err := cardFace(f, cardBodySize)
+	if err != nil {
+		return nil, err
+	}
+	brandFace, err := cardFace(f, cardBrandSize)
+	if err != nil {
+		return nil, err
+	}
+
+	stanza := cardStanza(song)
+	if !canDraw(titleFace, song.Title) || !canDraw(bodyFace, strings.Join(stanza, "\n")) {
+		return nil, errCardGlyphs
+	}
+
+	textWidth := cardWidth - 2*cardPadding
+	titleLines := wrapText(titleFace, song.Title, textWidth)
+	var bodyLines []string
+	for _, line := range stanza {
+		bodyLines = append(bodyLines, wrapText(bodyFace, line, textWidth)...)
+	}
+	if len(bodyLines) > cardMaxLines {
+		bodyLines = append(bodyLines[:cardMaxLines-1], "…")
+	}
+
+	titleHeight := cardTitleSize * 3 / 2
+	bodyHeight := cardBodySize * 3 / 2
+	height := cardPadding + len(titleLines)*titleHeight + cardBodySize +
+		len(bodyLines)*bodyHeight + cardBodySize + cardBrandSize*2 + cardPadding
+	if height < cardMinHeight {
+		height = cardMinHeight
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(cardBackground), image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, cardWidth, 14), image.NewUniform(cardAccent), image.Point{}, draw.Src)
+
+	y := cardPadding
+	drawLine := func(face font.Face, c color.Color, text string, lineHeight int) {
+		y += lineHeight
+		d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: face, Dot: fixed.P(cardPadding, y)}
+		d.DrawString(text)
+	}
+	for _, line := range titleLines {
+		drawLine(titleFace, cardAccent, line, titleHeight)
+	}
+	y += cardBodySize
+	for _, line := range bodyLines {
+		drawLine(bodyFace, cardText, line, bodyHeight)
+	}
+
+	y = height - cardPadding - cardBrandSize*2
+	drawLine(brandFace, cardMuted, cardBranding, cardBrandSize*2)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// cardFileIDs caches the Telegram file_id of each card sent, keyed by a hash
+// of the text drawn on it, so an unchanged card is uploaded only once.
+var cardFileIDs = struct {
+	sync.Mutex
+	ids map[string]string
+}{ids: make(map[string]string)}
+
+func cardKey(song *Song) string {
+	sum := sha256.Sum256([]byte(song.Title + "\x00" + strings.Join(cardStanza(song), "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// sendLyricCard sends song's lyric card with an HTML caption, uploading it
+// only when no card with the same text has been sent before.
+func sendLyricCard(bot *tgbotapi.BotAPI, chatID int64, song *Song, caption string, markup interface{}) error {
+	key := cardKey(song)
+	cardFileIDs.Lock()
+	fileID := cardFileIDs.ids[key]
+	cardFileIDs.Unlock()
+
+	if fileID != "" {
+		msg := tgbotapi.NewPhotoShare(chatID, fileID)
+		msg.Caption, msg.ParseMode, msg.ReplyMarkup = caption, tgbotapi.ModeHTML, markup
+		_, err := bot.Send(msg)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Cached lyric card for %q failed, uploading again: %v", song.Title, err)
+	}
+
+	data, err := renderLyricCard(song)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: "lyrics.png", Bytes: data})
+	msg.Caption, msg.ParseMode, msg.ReplyMarkup = caption, tgbotapi.ModeHTML, markup
+	sent, err := bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	if sent.Photo != nil && len(*sent.Photo) > 0 {
+		cardFileIDs.Lock()
+		cardFileIDs.ids[key] = (*sent.Photo)[len(*sent.Photo)-1].FileID
+		cardFileIDs.Unlock()
+	}
+	return nil
+}
+
+// handleCardCallback answers the "Share as image" button with the song's
+// lyric card, which can be forwarded or saved like any photo.
+func handleCardCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, payload string) {
+	song, err := store.Get(context.TODO(), payload)
+	if err != nil {
+		if err != ErrSongNotFound {
+			log.Printf("Failed to load song %s: %v", payload, err)
+		}
+		bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Sorry, I couldn't find the lyrics for that song."))
+		return
+	}
+
+	caption := fmt.Sprintf("<b>%s</b>", html.EscapeString(song.Title))
+	if err := sendLyricCard(bot, query.Message.Chat.ID, song, caption, nil); err != nil {
+		if err != errCardGlyphs && err != errCardsDisabled {
+			log.Printf("Failed to send lyric card for %q: %v", song.Title, err)
+		}
+		bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Sorry, I couldn't make an image of this song."))
+	}
+}
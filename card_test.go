@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+func TestRenderLyricCardGeez(t *testing.T) {
+	if _, err := loadCardFont(); err != nil {
+		t.Skipf("skipping: %v", err)
+	}
+	song := &Song{
+		Title:  "እግዚአብሔር እረኛዬ ነው",
+		Lyrics: "እግዚአብሔር እረኛዬ ነው\nየሚያሳጣኝ የለም።\n\nበለመለመ መስክ ያሳድረኛል።",
+	}
+
+	data, err := renderLyricCard(song)
+	if err != nil {
+		t.Fatalf("renderLyricCard: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("card is not a PNG: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != cardWidth || b.Dy() < cardMinHeight {
+		t.Errorf("card is %dx%d, want %d wide and at least %d high", b.Dx(), b.Dy(), cardWidth, cardMinHeight)
+	}
+
+	// The title is drawn in the accent colour below the top bar.
+	inked := false
+	for y := cardPadding; y < cardPadding+2*cardTitleSize && !inked; y++ {
+		for x := cardPadding; x < cardWidth-cardPadding; x++ {
+			if r, g, b, _ := img.At(x, y).RGBA(); r>>8 > 0x80 && g>>8 > 0x80 && b>>8 < 0x80 {
+				inked = true
+				break
+			}
+		}
+	}
+	if !inked {
+		t.Errorf("no title drawn on the card")
+	}
+}
+
+func TestParseCardFontRejectsLatinOnly(t *testing.T) {
+	if _, err := parseCardFont(goregular.TTF); err == nil {
+		t.Errorf("parseCardFont accepted a font without Ethiopic glyphs")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	f, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	face, err := cardFace(f, cardBodySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	width := 400
+
+	text := "Amazing grace how sweet the sound that saved a wretch like me " + strings.Repeat("x", 60)
+	lines := wrapText(face, text, width)
+	if got := strings.Join(lines, ""); strings.ReplaceAll(got, " ", "") != strings.ReplaceAll(text, " ", "") {
+		t.Errorf("wrapText lost text: %q", lines)
+	}
+	for _, line := range lines {
+		if w := font.MeasureString(face, line).Ceil(); w > width {
+			t.Errorf("line %q is %d pixels wide, over %d", line, w, width)
+		}
+	}
+}
+
+func TestCardsDisabledWithoutFont(t *testing.T) {
+	if _, err := loadCardFont(); err == nil {
+		t.Skip("skipping: a card font is present")
+	}
+	if _, err := renderLyricCard(&Song{Title: "Amazing Grace", Lyrics: "Amazing grace"}); err != errCardsDisabled {
+		t.Errorf("renderLyricCard error = %v, want errCardsDisabled", err)
+	}
+	if row := songActions(&Song{ID: "1"}).InlineKeyboard[0]; len(row) != 1 {
+		t.Errorf("song keyboard offers %d buttons without a card font, want only Follow", len(row))
+	}
+}
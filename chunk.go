@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// maxMessageRunes is Telegram's limit on the text of a single message.
+const maxMessageRunes = 4096
+
+// maxCaptionRunes is Telegram's limit on a photo or document caption.
+const maxCaptionRunes = 1024
+
+// partHeaderRunes is room reserved for the "(12/34)\n" part counter.
+const partHeaderRunes = 12
+
+// splitMessage splits text into chunks of at most limit runes, preferring to
+// break between stanzas, then between lines, and only cutting inside a line
+// when a single line is longer than limit. Such cuts never fall inside an
+// HTML tag or entity.
+func splitMessage(text string, limit int) []string {
+	if utf8.RuneCountInString(text) <= limit {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(current.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		current.Reset()
+	}
+	add := func(piece, sep string) {
+		if current.Len() > 0 && utf8.RuneCountInString(current.String())+utf8.RuneCountInString(sep+piece) > limit {
+			flush()
+		}
+		if current.Len() > 0 {
+			current.WriteString(sep)
+		}
+		current.WriteString(piece)
+	}
+
+	for _, stanza := range strings.Split(text, "\n\n") {
+		if utf8.RuneCountInString(stanza) <= limit {
+			add(stanza, "\n\n")
+			continue
+		}
+		flush()
+		for _, line := range strings.Split(stanza, "\n") {
+			for utf8.RuneCountInString(line) > limit {
+				flush()
+				runes := []rune(line)
+				n := cutPoint(runes, limit)
+				chunks = append(chunks, string(runes[:n]))
+				line = string(runes[n:])
+			}
+			add(line, "\n")
+		}
+		flush()
+	}
+	flush()
+	return chunks
+}
+
+// maxEntityRunes is the length of the longest HTML entity Telegram accepts,
+// such as "&#x1F600;".
+const maxEntityRunes = 10
+
+// cutPoint returns where to cut runes to keep at most limit of them, moving
+// the cut back to the start of a tag or entity it would otherwise split.
+func cutPoint(runes []rune, limit int) int {
+	head := runes[:limit]
+	n := limit
+	for i := len(head) - 1; i >= 0; i-- {
+		if head[i] == '>' {
+			break
+		}
+		if head[i] == '<' {
+			n = i
+			break
+		}
+	}
+	for i := n - 1; i >= 0 && i >= n-maxEntityRunes; i-- {
+		if head[i] == ';' {
+			break
+		}
+		if head[i] == '&' {
+			n = i
+			break
+		}
+	}
+	if n == 0 {
+		// A tag longer than a whole message cannot be kept together.
+		return limit
+	}
+	return n
+}
+
+// numberParts prefixes each chunk with "(i/n)" when there is more than one.
+func numberParts(chunks []string) []string {
+	if len(chunks) < 2 {
+		return chunks
+	}
+	parts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		parts[i] = fmt.Sprintf("(%d/%d)\n%s", i+1, len(chunks), chunk)
+	}
+	return parts
+}
+
+// sendLongText sends text split across as many numbered messages as needed,
+// using parseMode ("" for plain text) and attaching replyMarkup, if not nil,
+// to the last message. It stops and returns the error of the first message
+// that fails. Chunks break between lines where they can and never inside a
+// tag or entity, but a line longer than a message is cut, so markup around
+// such a line should open and close on a short line of its own.
+func sendLongText(bot *tgbotapi.BotAPI, chatID int64, text, parseMode string, replyMarkup interface{}) error {
+	parts := numberParts(splitMessage(text, maxMessageRunes-partHeaderRunes))
+	for i, part := range parts {
+		msg := tgbotapi.NewMessage(chatID, part)
+		msg.ParseMode = parseMode
+		if i == len(parts)-1 {
+			msg.ReplyMarkup = replyMarkup
+		}
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Failed to send message to %d: %v", chatID, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessageKeepsTagsAndEntities(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"tag across the limit", strings.Repeat("a", 8) + "<b>bold</b>"},
+		{"entity across the limit", strings.Repeat("a", 8) + "&amp;&amp;"},
+		{"numeric entity", strings.Repeat("a", 7) + "&#x1F600;b"},
+	}
+	for _, tt := range tests {
+		chunks := splitMessage(tt.line, 10)
+		if got := strings.Join(chunks, ""); got != tt.line {
+			t.Errorf("%s: chunks %q do not rejoin to the input", tt.name, chunks)
+		}
+		for _, chunk := range chunks {
+			if utf8.RuneCountInString(chunk) > 10 {
+				t.Errorf("%s: chunk %q is over the limit", tt.name, chunk)
+			}
+			if strings.Count(chunk, "<") != strings.Count(chunk, ">") {
+				t.Errorf("%s: chunk %q splits a tag", tt.name, chunk)
+			}
+			if strings.Count(chunk, "&") != strings.Count(chunk, ";") {
+				t.Errorf("%s: chunk %q splits an entity", tt.name, chunk)
+			}
+		}
+	}
+}
+
+func TestSplitMessagePrefersStanzaBreaks(t *testing.T) {
+	text := "one one\ntwo two\n\nthree three"
+	chunks := splitMessage(text, 18)
+	want := []string{"one one\ntwo two", "three three"}
+	if strings.Join(chunks, "|") != strings.Join(want, "|") {
+		t.Errorf("splitMessage = %q, want %q", chunks, want)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// fakeTelegram stands in for the Bot API. It records every request and
+// answers each with a message, so handlers can run against a real BotAPI.
+type fakeTelegram struct {
+	mu     sync.Mutex
+	calls  []fakeCall
+	nextID int
+}
+
+// fakeCall is one request the bot made.
+type fakeCall struct {
+	Method string
+	Params url.Values
+}
+
+// fakeBotID is the user ID of the bot under test.
+const fakeBotID = 1000
+
+// newFakeBot returns a bot whose requests go to a fakeTelegram.
+func newFakeBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	f := &fakeTelegram{nextID: 500}
+	bot := &tgbotapi.BotAPI{
+		Token:  "test",
+		Client: &http.Client{Transport: f},
+		Self:   tgbotapi.User{ID: fakeBotID, UserName: "choir_bot", IsBot: true},
+		Buffer: 100,
+	}
+	return bot, f
+}
+
+func (f *fakeTelegram) RoundTrip(req *http.Request) (*http.Response, error) {
+	params := url.Values{}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		if err := req.ParseMultipartForm(32 << 20); err != nil {
+			return nil, err
+		}
+		params = req.MultipartForm.Value
+	} else if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if params, err = url.ParseQuery(string(body)); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	method := path.Base(req.URL.Path)
+	f.calls = append(f.calls, fakeCall{Method: method, Params: params})
+	f.nextID++
+	id := f.nextID
+	f.mu.Unlock()
+
+	chatID, _ := strconv.ParseInt(params.Get("chat_id"), 10, 64)
+	message := map[string]interface{}{
+		"message_id": id,
+		"from":       map[string]interface{}{"id": fakeBotID, "is_bot": true},
+		"chat":       map[string]interface{}{"id": chatID},
+		"text":       params.Get("text"),
+	}
+	var result interface{} = message
+	if method == "sendMediaGroup" {
+		result = []interface{}{message}
+	}
+	body, err := json.Marshal(map[string]interface{}{"ok": true, "result": result})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(string(body))),
+		Request:    req,
+	}, nil
+}
+
+// texts returns the text of every message sent so far and forgets them.
+func (f *fakeTelegram) texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, call := range f.calls {
+		if text := call.Params.Get("text"); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	f.calls = nil
+	return texts
+}
+
+// lastMessageID returns the ID the fake gave the most recent message.
+func (f *fakeTelegram) lastMessageID() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.nextID
+}
+
+// textMessage returns a message from user in chat. Chats with the same ID as
+// the user are private, as on Telegram; others are groups.
+func textMessage(chatID int64, userID int, text string) *tgbotapi.Message {
+	chatType := "group"
+	if chatID == int64(userID) {
+		chatType = "private"
+	}
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: chatType},
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	return message
+}
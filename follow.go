@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// callbackFollowV1 drives "follow along" mode: follow:v1:<song id>:<step>,
+// where step indexes the song's repeat order.
+const callbackFollowV1 = "follow:v1"
+
+func followCallbackData(songID string, step int) string {
+	return fmt.Sprintf("%s:%s:%d", callbackFollowV1, songID, step)
+}
+
+// followButton starts follow-along mode for song.
+func followButton(song *Song) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData("🎤 Follow along", followCallbackData(song.ID, -1))
+}
+
+// renderFollowStep renders one sung section of song, in HTML, with the
+// keyboard that moves to the neighbouring sections.
+func renderFollowStep(song *Song, st Structure, step int) (string, tgbotapi.InlineKeyboardMarkup) {
+	sec := st.Sections[st.Order[step]]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b> · %d/%d\n\n", html.EscapeString(song.Title), step+1, len(st.Order))
+	fmt.Fprintf(&b, "<i>%s</i>\n", html.EscapeString(sec.Label))
+	b.WriteString(html.EscapeString(strings.Join(sec.Lines, "\n")))
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if step > 0 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", followCallbackData(song.ID, step-1)))
+	}
+	if step < len(st.Order)-1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", followCallbackData(song.ID, step+1)))
+	}
+	if len(nav) == 0 {
+		return b.String(), tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+	return b.String(), tgbotapi.NewInlineKeyboardMarkup(nav)
+}
+
+// handleFollowCallback shows one section per message. Step -1 comes from the
+// song view and starts a new follow-along message; any other step edits the
+// pressed message in place.
+func handleFollowCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, payload string) {
+	id, stepStr, _ := strings.Cut(payload, ":")
+	step, err := strconv.Atoi(stepStr)
+	if err != nil {
+		log.Printf("Invalid follow callback %q", payload)
+		return
+	}
+
+	song, err := store.Get(context.TODO(), id)
+	if err != nil {
+		if err != ErrSongNotFound {
+			log.Printf("Failed to load song %s: %v", id, err)
+		}
+		bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"Sorry, I couldn't find the lyrics for that song."))
+		return
+	}
+
+	st := song.Structured()
+	if len(st.Order) == 0 {
+		return
+	}
+
+	if step < 0 {
+		text, keyboard := renderFollowStep(song, st, 0)
+		msg := tgbotapi.NewMessage(query.Message.Chat.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = keyboard
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Failed to start follow-along: %v", err)
+		}
+		return
+	}
+
+	if step >= len(st.Order) {
+		step = len(st.Order) - 1
+	}
+	text, keyboard := renderFollowStep(song, st, step)
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.ParseMode = tgbotapi.ModeHTML
+	edit.ReplyMarkup = &keyboard
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Failed to change follow-along section: %v", err)
+	}
+}
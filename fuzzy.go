@@ -0,0 +1,85 @@
+package main
+
+import "unicode/utf8"
+
+// Fuzzy matches score below exact, prefix and substring matches so a typo
+// never outranks a title the user actually typed.
+const (
+	maxFuzzyScore      = 55
+	minTrigramSimilar  = 0.35
+	minEditSimilarity  = 0.7
+	minFuzzyQueryRunes = 3
+)
+
+// fuzzyScore scores a normalized query against a normalized title using
+// trigram similarity for whole-title matches and edit distance against the
+// start of the title for partially typed ones. It returns 0 for no match.
+func fuzzyScore(key, title string) int {
+	if utf8.RuneCountInString(key) < minFuzzyQueryRunes || title == "" {
+		return 0
+	}
+
+	best := 0.0
+	if sim := trigramSimilarity(key, title); sim >= minTrigramSimilar {
+		best = sim
+	}
+
+	q, t := []rune(key), []rune(title)
+	if len(t) > len(q) {
+		t = t[:len(q)]
+	}
+	if sim := 1 - float64(levenshtein(q, t))/float64(len(q)); sim >= minEditSimilarity && sim > best {
+		best = sim
+	}
+
+	return int(best * maxFuzzyScore)
+}
+
+// trigrams returns the set of three-rune windows of s, padded so that the
+// start and end of the string form trigrams of their own.
+func trigrams(s string) map[string]struct{} {
+	r := []rune("  " + s + " ")
+	set := make(map[string]struct{}, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		set[string(r[i:i+3])] = struct{}{}
+	}
+	return set
+}
+
+// trigramSimilarity returns the Jaccard similarity of the trigram sets of a
+// and b, between 0 and 1.
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for g := range ta {
+		if _, ok := tb[g]; ok {
+			shared++
+		}
+	}
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"grace", "grace", 0},
+		{"kitten", "sitting", 3},
+		{"grcae", "grace", 2},
+		{"ሰላም", "ሳላም", 1},
+	}
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestTrigramSimilarity(t *testing.T) {
+	if got := trigramSimilarity("amazing grace", "amazing grace"); got != 1 {
+		t.Errorf("identical strings: similarity = %v, want 1", got)
+	}
+	if got := trigramSimilarity("abc", "xyz"); got != 0 {
+		t.Errorf("disjoint strings: similarity = %v, want 0", got)
+	}
+	close, far := trigramSimilarity("amazing grase", "amazing grace"), trigramSimilarity("blessed assurance", "amazing grace")
+	if close <= far {
+		t.Errorf("typo similarity %v not above unrelated %v", close, far)
+	}
+	if a, b := trigramSimilarity("grace", "graces"), trigramSimilarity("graces", "grace"); a != b {
+		t.Errorf("similarity not symmetric: %v and %v", a, b)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		key, title string
+		match      bool
+	}{
+		{"typo in a word", "amazing grase", "amazing grace", true},
+		{"swapped letters", "amzaing grace", "amazing grace", true},
+		{"partly typed with a typo", "amazimg", "amazing grace", true},
+		{"ge'ez typo", "ሳላም ለኪ", "ሰላም ለኪ", true},
+		{"unrelated", "blessed assurance", "amazing grace", false},
+		{"too short", "am", "amazing grace", false},
+		{"empty title", "amazing", "", false},
+	}
+	for _, tt := range tests {
+		got := fuzzyScore(tt.key, tt.title)
+		if got < 0 || got > maxFuzzyScore {
+			t.Errorf("%s: fuzzyScore = %d, outside 0..%d", tt.name, got, maxFuzzyScore)
+		}
+		if (got > 0) != tt.match {
+			t.Errorf("%s: fuzzyScore(%q, %q) = %d, want match %v", tt.name, tt.key, tt.title, got, tt.match)
+		}
+	}
+	// A typo never outranks a title the user typed the start of.
+	if fuzzy, prefix := fuzzyScore("amazing grac", "amazing grace"), titleScore("amazing", "amazing grace"); fuzzy >= prefix {
+		t.Errorf("fuzzy score %d not below prefix score %d", fuzzy, prefix)
+	}
+}
+
+// countingStore counts calls to All.
+type countingStore struct {
+	SongStore
+	all int
+}
+
+func (s *countingStore) All(ctx context.Context) ([]Song, error) {
+	s.all++
+	return s.SongStore.All(ctx)
+}
+
+func TestSearchTitlesUsesIndex(t *testing.T) {
+	backend := &countingStore{SongStore: NewMemorySongStore()}
+	for _, title := range []string{"Amazing Grace", "Blessed Assurance"} {
+		if err := backend.Insert(context.Background(), &Song{Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store, err := NewIndexedSongStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Insert(context.Background(), &Song{Title: "Amazing Love"}); err != nil {
+		t.Fatal(err)
+	}
+	backend.all = 0
+
+	results := searchTitles(store, "amazng grace")
+	if len(results) == 0 || results[0].Title != "Amazing Grace" {
+		t.Errorf("searchTitles = %v, want Amazing Grace first", results)
+	}
+	if results := searchTitles(store, "amazing"); len(results) != 2 {
+		t.Errorf("searchTitles(amazing) = %v, want both Amazing songs", results)
+	}
+	if backend.all != 0 {
+		t.Errorf("searchTitles loaded every song from the backend %d times", backend.all)
+	}
+}
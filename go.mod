@@ -5,7 +5,7 @@ go 1.21
 require (
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/joho/godotenv v1.5.1
-	
+	golang.org/x/image v0.18.0
 )
 
 require (
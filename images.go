@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Image is a photo an admin sent to the bot.
+type Image struct {
+	// FileID is the Telegram file_id of the photo.
+	FileID string
+	// Open downloads the photo; the caller closes the returned reader.
+	// Stores read it through openImage, which validates the content.
+	Open func() (io.ReadCloser, error)
+}
+
+// maxImageBytes bounds the size of an uploaded image.
+const maxImageBytes = 10 << 20
+
+// allowedImageTypes are the sniffed content types accepted as song images,
+// with the file extension each is saved under.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// ErrImageTooLarge is returned while reading an image over maxImageBytes.
+var ErrImageTooLarge = fmt.Errorf("image is larger than %d MB", maxImageBytes>>20)
+
+// ErrUnsupportedImage is returned for content that is not an allowed image
+// type.
+var ErrUnsupportedImage = errors.New("unsupported image type")
+
+// openImage opens img and validates it with validateImage. Every store reads
+// images through it, whatever their source.
+func openImage(img Image) (io.ReadCloser, string, error) {
+	body, err := img.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	return validateImage(body)
+}
+
+// validateImage checks that body holds an allowed image type by sniffing its
+// first bytes, and returns that type and a reader over the whole body that
+// fails with ErrImageTooLarge once more than maxImageBytes have been read.
+// Stores copy from the returned reader, so an oversized upload is never kept.
+func validateImage(body io.ReadCloser) (io.ReadCloser, string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		body.Close()
+		return nil, "", err
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		body.Close()
+		return nil, "", fmt.Errorf("%w %s", ErrUnsupportedImage, contentType)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: &limitedReader{r: io.MultiReader(bytes.NewReader(head), body), left: maxImageBytes},
+		Closer: body,
+	}, contentType, nil
+}
+
+// limitedReader is like io.LimitedReader but errors instead of truncating.
+type limitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left <= 0 {
+		// Probe for one more byte so an image of exactly the limit passes.
+		var probe [1]byte
+		if n, _ := l.r.Read(probe[:]); n > 0 {
+			return 0, ErrImageTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.left {
+		p = p[:l.left]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	return n, err
+}
+
+// ImageStore keeps song images. Put returns a reference that is saved as
+// Song.Image; URL turns a reference into something Telegram's sendPhoto
+// accepts. References that a store did not create, such as image URLs
+// saved before the store was configured, are passed through URL unchanged.
+type ImageStore interface {
+	Put(ctx context.Context, img Image) (string, error)
+	URL(ref string) string
+	Delete(ctx context.Context, ref string) error
+}
+
+// images is the configured ImageStore, set up in main.
+var images ImageStore = TelegramImageStore{}
+
+// openImageStore builds the ImageStore selected by IMAGE_STORE: "imgur" (the
+// default, using IMGUR_CLIENT_ID), "local" (IMAGE_DIR, served under
+// PUBLIC_URL by the bot's HTTP server) or "telegram".
+func openImageStore() (ImageStore, error) {
+	switch backend := os.Getenv("IMAGE_STORE"); backend {
+	case "", "imgur":
+		return NewImgurImageStore(os.Getenv("IMGUR_CLIENT_ID")), nil
+	case "local":
+		dir := os.Getenv("IMAGE_DIR")
+		if dir == "" {
+			dir = "images"
+		}
+		publicURL := os.Getenv("PUBLIC_URL")
+		if publicURL == "" {
+			return nil, errors.New("IMAGE_STORE=local requires PUBLIC_URL")
+		}
+		return NewLocalImageStore(dir, publicURL)
+	case "telegram":
+		return TelegramImageStore{}, nil
+	default:
+		return nil, fmt.Errorf("unknown IMAGE_STORE %q", backend)
+	}
+}
+
+// TelegramImageStore keeps no copy of images: the reference is the Telegram
+// file_id itself, which sendPhoto accepts directly. Put still downloads the
+// image once to validate it.
+type TelegramImageStore struct{}
+
+func (TelegramImageStore) Put(ctx context.Context, img Image) (string, error) {
+	if img.FileID == "" {
+		return "", errors.New("telegram image store needs a file_id")
+	}
+	body, _, err := openImage(img)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return "", err
+	}
+	return img.FileID, nil
+}
+
+func (TelegramImageStore) URL(ref string) string { return ref }
+
+// Delete is a no-op: Telegram does not let bots delete uploaded files.
+func (TelegramImageStore) Delete(ctx context.Context, ref string) error { return nil }
+
+// localImagePrefix marks references created by LocalImageStore.
+const localImagePrefix = "local:"
+
+// LocalImageStore saves images in a directory on disk and serves them over
+// the bot's HTTP server under /images/.
+type LocalImageStore struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocalImageStore stores images in dir; publicURL is the externally
+// reachable base URL of the bot's HTTP server.
+func NewLocalImageStore(dir, publicURL string) (*LocalImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalImageStore{dir: dir, publicURL: strings.TrimSuffix(publicURL, "/")}, nil
+}
+
+func (s *LocalImageStore) Put(ctx context.Context, img Image) (string, error) {
+	body, contentType, err := openImage(img)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	// The extension sets the Content-Type the image is served with.
+	name := hex.EncodeToString(b) + allowedImageTypes[contentType]
+
+	out, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, body); err != nil {
+		out.Close()
+		return "", err
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(out.Name(), filepath.Join(s.dir, name)); err != nil {
+		return "", err
+	}
+	return localImagePrefix + name, nil
+}
+
+func (s *LocalImageStore) URL(ref string) string {
+	name, ok := strings.CutPrefix(ref, localImagePrefix)
+	if !ok {
+		return ref
+	}
+	return s.publicURL + "/images/" + name
+}
+
+func (s *LocalImageStore) Delete(ctx context.Context, ref string) error {
+	name, ok := strings.CutPrefix(ref, localImagePrefix)
+	if !ok {
+		return nil
+	}
+	err := os.Remove(filepath.Join(s.dir, filepath.Base(name)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Handler serves the stored images; mount it at /images/. Directory
+// listings are refused, so images can only be fetched by name.
+func (s *LocalImageStore) Handler() http.Handler {
+	files := http.StripPrefix("/images/", http.FileServer(http.Dir(s.dir)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// ImgurImageStore uploads images anonymously to Imgur. References are the
+// image link with the deletehash as URL fragment, so images can be removed.
+type ImgurImageStore struct {
+	clientID string
+	client   *http.Client
+}
+
+// NewImgurImageStore returns an ImgurImageStore using the given client ID.
+func NewImgurImageStore(clientID string) *ImgurImageStore {
+	return &ImgurImageStore{clientID: clientID, client: &http.Client{}}
+}
+
+func (s *ImgurImageStore) Put(ctx context.Context, img Image) (string, error) {
+	body, contentType, err := openImage(img)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+	part, err := writer.CreateFormFile("image", "image"+allowedImageTypes[contentType])
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(part, body)
+	if err != nil {
+		return "", err
+	}
+	writer.Close()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://api.imgur.com/3/upload", &requestBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Client-ID "+s.clientID)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data struct {
+			Link       string `json:"link"`
+			DeleteHash string `json:"deletehash"`
+		} `json:"data"`
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if !result.Success || result.Data.Link == "" {
+		return "", fmt.Errorf("failed to upload image: imgur status %d", resp.StatusCode)
+	}
+
+	ref := result.Data.Link
+	if result.Data.DeleteHash != "" {
+		ref += "#" + result.Data.DeleteHash
+	}
+	return ref, nil
+}
+
+func (s *ImgurImageStore) URL(ref string) string {
+	link, _, _ := strings.Cut(ref, "#")
+	return link
+}
+
+func (s *ImgurImageStore) Delete(ctx context.Context, ref string) error {
+	_, deleteHash, ok := strings.Cut(ref, "#")
+	if !ok || !strings.Contains(ref, "imgur.com/") {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", "https://api.imgur.com/3/image/"+deleteHash, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Client-ID "+s.clientID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete image: imgur status %d", resp.StatusCode)
+	}
+	return nil
+}
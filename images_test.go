@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// testImage returns an Image whose content is data.
+func testImage(fileID string, data []byte) Image {
+	return Image{
+		FileID: fileID,
+		Open: func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		},
+	}
+}
+
+func TestLocalImageStoreParallelPuts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalImageStore(dir, "https://bot.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 16
+	payloads := make([][]byte, n)
+	for i := range payloads {
+		body := bytes.Repeat([]byte(fmt.Sprintf("image %d;", i)), 1000*(i+1))
+		payloads[i] = append(append([]byte(nil), pngSignature...), body...)
+	}
+
+	refs := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range payloads {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			refs[i], errs[i] = store.Put(context.Background(), testImage(fmt.Sprint(i), payloads[i]))
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i, ref := range refs {
+		if errs[i] != nil {
+			t.Errorf("Put %d: %v", i, errs[i])
+			continue
+		}
+		if seen[ref] {
+			t.Errorf("Put %d reused reference %q", i, ref)
+		}
+		seen[ref] = true
+		got, err := os.ReadFile(filepath.Join(dir, strings.TrimPrefix(ref, localImagePrefix)))
+		if err != nil {
+			t.Errorf("Put %d: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(got, payloads[i]) {
+			t.Errorf("Put %d stored %d bytes that differ from its %d-byte input", i, len(got), len(payloads[i]))
+		}
+	}
+}
+
+func TestLocalImageStoreRejectsBadImages(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"oversize", append(append([]byte(nil), pngSignature...), make([]byte, maxImageBytes)...), ErrImageTooLarge},
+		{"gif", []byte("GIF89a" + strings.Repeat("x", 100)), ErrUnsupportedImage},
+		{"html", []byte("<html><body>not an image</body></html>"), ErrUnsupportedImage},
+		{"empty", nil, ErrUnsupportedImage},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		store, err := NewLocalImageStore(dir, "https://bot.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Put(context.Background(), testImage("id", tt.data)); !errors.Is(err, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("%s: rejected image left %d files behind", tt.name, len(entries))
+		}
+	}
+}
+
+func TestLocalImageStoreAcceptsImageAtLimit(t *testing.T) {
+	store, err := NewLocalImageStore(t.TempDir(), "https://bot.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(append([]byte(nil), pngSignature...), make([]byte, maxImageBytes-len(pngSignature))...)
+	if _, err := store.Put(context.Background(), testImage("id", data)); err != nil {
+		t.Errorf("image of exactly %d bytes: %v", maxImageBytes, err)
+	}
+}
+
+func TestTelegramImageStoreValidates(t *testing.T) {
+	var store TelegramImageStore
+	ref, err := store.Put(context.Background(), testImage("file-1", append(append([]byte(nil), pngSignature...), "ok"...)))
+	if err != nil || ref != "file-1" {
+		t.Errorf("valid image: got %q, %v; want %q", ref, err, "file-1")
+	}
+	if _, err := store.Put(context.Background(), testImage("file-2", []byte("GIF89a"))); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("gif: got %v, want %v", err, ErrUnsupportedImage)
+	}
+}
+
+func TestImageErrorText(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrImageTooLarge, "Failed to upload image: image is larger than 10 MB."},
+		{fmt.Errorf("%w image/gif", ErrUnsupportedImage), "Failed to upload image: please send a JPEG, PNG or WebP image."},
+		{errors.New("connection reset"), "Failed to upload image."},
+	}
+	for _, tt := range tests {
+		if got := imageErrorText(tt.err); got != tt.want {
+			t.Errorf("imageErrorText(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestLocalImageStoreServesByType(t *testing.T) {
+	store, err := NewLocalImageStore(t.TempDir(), "https://bot.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := store.Handler()
+	tests := []struct {
+		data []byte
+		ext  string
+		want string
+	}{
+		{[]byte("\xff\xd8\xff\xe0 jpeg"), ".jpg", "image/jpeg"},
+		{append(append([]byte(nil), pngSignature...), " png"...), ".png", "image/png"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 webp"), ".webp", "image/webp"},
+	}
+	for _, tt := range tests {
+		ref, err := store.Put(context.Background(), testImage("", tt.data))
+		if err != nil {
+			t.Fatalf("Put %s: %v", tt.want, err)
+		}
+		if !strings.HasSuffix(ref, tt.ext) {
+			t.Errorf("%s stored as %q, want a %s name", tt.want, ref, tt.ext)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", strings.TrimPrefix(store.URL(ref), "https://bot.example.com"), nil))
+		if got := rec.Header().Get("Content-Type"); rec.Code != http.StatusOK || got != tt.want {
+			t.Errorf("GET %s: %d %q, want 200 %q", ref, rec.Code, got, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/images/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /images/: %d, want the directory listing refused", rec.Code)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxLyricsResults is the number of songs returned by a lyrics search.
+const maxLyricsResults = 10
+
+// LyricsMatch is a song whose lyrics contain a searched phrase, with the line
+// that matched best.
+type LyricsMatch struct {
+	Song Song
+	Line string
+	// Phrase is true when Line contains the whole phrase rather than only
+	// some of its words.
+	Phrase bool
+	hits   int
+}
+
+// tokenize splits text into normalized words. normalizeTitle already folds
+// homophones and turns Ethiopic separators such as ፡ and ። into spaces.
+func tokenize(text string) []string {
+	return strings.Fields(normalizeTitle(text))
+}
+
+// LyricsIndex is an inverted index from normalized lyric words to songs.
+type LyricsIndex struct {
+	mu       sync.RWMutex
+	songs    map[string]Song
+	postings map[string]map[string]struct{} // word -> song IDs
+}
+
+// NewLyricsIndex returns an index over songs.
+func NewLyricsIndex(songs []Song) *LyricsIndex {
+	idx := &LyricsIndex{
+		songs:    make(map[string]Song),
+		postings: make(map[string]map[string]struct{}),
+	}
+	for _, song := range songs {
+		idx.add(song)
+	}
+	return idx
+}
+
+// Put adds or replaces song in the index.
+func (idx *LyricsIndex) Put(song Song) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(song.ID)
+	idx.add(song)
+}
+
+// Remove drops the song with the given ID from the index.
+func (idx *LyricsIndex) Remove(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+}
+
+// Songs returns every indexed song, sorted by title.
+func (idx *LyricsIndex) Songs() []Song {
+	idx.mu.RLock()
+	songs := make([]Song, 0, len(idx.songs))
+	for _, song := range idx.songs {
+		songs = append(songs, song)
+	}
+	idx.mu.RUnlock()
+	sort.Slice(songs, func(i, j int) bool { return songs[i].Title < songs[j].Title })
+	return songs
+}
+
+func (idx *LyricsIndex) add(song Song) {
+	idx.songs[song.ID] = song
+	for _, word := range tokenize(song.Lyrics) {
+		ids, ok := idx.postings[word]
+		if !ok {
+			ids = make(map[string]struct{})
+			idx.postings[word] = ids
+		}
+		ids[song.ID] = struct{}{}
+	}
+}
+
+func (idx *LyricsIndex) remove(id string) {
+	song, ok := idx.songs[id]
+	if !ok {
+		return
+	}
+	delete(idx.songs, id)
+	for _, word := range tokenize(song.Lyrics) {
+		if ids, ok := idx.postings[word]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.postings, word)
+			}
+		}
+	}
+}
+
+// Search returns songs whose lyrics contain every word of phrase, ranked so
+// that songs with the exact phrase on one line come first.
+func (idx *LyricsIndex) Search(phrase string) []LyricsMatch {
+	phrase = capQuery(phrase)
+	words := tokenize(phrase)
+	if len(words) == 0 {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Intersect postings, starting from the rarest word.
+	sort.Slice(words, func(i, j int) bool {
+		return len(idx.postings[words[i]]) < len(idx.postings[words[j]])
+	})
+	var candidates []string
+	for id := range idx.postings[words[0]] {
+		candidates = append(candidates, id)
+	}
+	for _, word := range words[1:] {
+		ids := idx.postings[word]
+		kept := candidates[:0]
+		for _, id := range candidates {
+			if _, ok := ids[id]; ok {
+				kept = append(kept, id)
+			}
+		}
+		candidates = kept
+	}
+
+	key := normalizeTitle(phrase)
+	matches := make([]LyricsMatch, 0, len(candidates))
+	for _, id := range candidates {
+		matches = append(matches, bestLine(idx.songs[id], key, words))
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Phrase != matches[j].Phrase {
+			return matches[i].Phrase
+		}
+		if matches[i].hits != matches[j].hits {
+			return matches[i].hits > matches[j].hits
+		}
+		return matches[i].Song.Title < matches[j].Song.Title
+	})
+	if len(matches) > maxLyricsResults {
+		matches = matches[:maxLyricsResults]
+	}
+	return matches
+}
+
+// bestLine picks the lyric line that contains the normalized phrase key, or
+// failing that the line containing the most of its words.
+func bestLine(song Song, key string, words []string) LyricsMatch {
+	match := LyricsMatch{Song: song}
+	for _, line := range strings.Split(song.Lyrics, "\n") {
+		norm := normalizeTitle(line)
+		if norm == "" {
+			continue
+		}
+		if strings.Contains(norm, key) {
+			match.Line, match.Phrase, match.hits = strings.TrimSpace(line), true, len(words)
+			return match
+		}
+		lineWords := make(map[string]struct{})
+		for _, w := range strings.Fields(norm) {
+			lineWords[w] = struct{}{}
+		}
+		hits := 0
+		for _, w := range words {
+			if _, ok := lineWords[w]; ok {
+				hits++
+			}
+		}
+		if hits > match.hits {
+			match.Line, match.hits = strings.TrimSpace(line), hits
+		}
+	}
+	return match
+}
+
+// IndexedSongStore wraps a SongStore and keeps a LyricsIndex in step with
+// every change made through it.
+type IndexedSongStore struct {
+	SongStore
+	index *LyricsIndex
+}
+
+// NewIndexedSongStore indexes every song currently in store.
+func NewIndexedSongStore(store SongStore) (*IndexedSongStore, error) {
+	songs, err := store.All(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	return &IndexedSongStore{SongStore: store, index: NewLyricsIndex(songs)}, nil
+}
+
+// SearchLyrics returns songs whose lyrics contain phrase.
+func (s *IndexedSongStore) SearchLyrics(phrase string) []LyricsMatch {
+	return s.index.Search(phrase)
+}
+
+// Songs returns every song from the index, without a round trip to the
+// backend.
+func (s *IndexedSongStore) Songs() []Song {
+	return s.index.Songs()
+}
+
+func (s *IndexedSongStore) Insert(ctx context.Context, song *Song) error {
+	if err := s.SongStore.Insert(ctx, song); err != nil {
+		return err
+	}
+	s.index.Put(*song)
+	return nil
+}
+
+func (s *IndexedSongStore) Update(ctx context.Context, song *Song) error {
+	if err := s.SongStore.Update(ctx, song); err != nil {
+		return err
+	}
+	s.index.Put(*song)
+	return nil
+}
+
+func (s *IndexedSongStore) Delete(ctx context.Context, id string) error {
+	if err := s.SongStore.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.index.Remove(id)
+	return nil
+}
+
+// lyricsSearcher is implemented by stores that can search inside lyrics.
+type lyricsSearcher interface {
+	SearchLyrics(phrase string) []LyricsMatch
+}
+
+// searchLyrics searches lyrics through the store's index when it has one, and
+// otherwise by indexing every song for this one query.
+func searchLyrics(store SongStore, phrase string) []LyricsMatch {
+	if searcher, ok := store.(lyricsSearcher); ok {
+		return searcher.SearchLyrics(phrase)
+	}
+	songs, err := store.All(context.TODO())
+	if err != nil {
+		log.Printf("Failed to load songs for lyrics search: %v", err)
+		return nil
+	}
+	return NewLyricsIndex(songs).Search(phrase)
+}
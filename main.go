@@ -1,48 +1,36 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 
 	"log"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var adminIDs = []int{547900737, 1237680623} // Admin Telegram ID
 
-type UserState struct {
-	Stage     string
-	Title     string
-	Lyrics    string
-	Category  string
-	IsEditing bool
-	EditField string
-}
-
-var userStates = make(map[int]UserState)
-
 func main() {
 	// Load environment variables from .env file
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		log.Printf("No .env file loaded, using process environment: %v", err)
 	}
 
 	// Retrieve environment variables
 	telegramBotToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	mongoDBURI := os.Getenv("MONGODB_URI")
 
 	// Get port from environment variable or use default
 	port := os.Getenv("PORT")
@@ -50,14 +38,24 @@ func main() {
 		port = "8080" // default port
 	}
 
-	// Connect to MongoDB
-	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(mongoDBURI))
+	configurePagination(os.Getenv("PAGE_SIZE"), os.Getenv("PAGE_COLUMNS"))
+
+	if _, err := loadCardFont(); err != nil {
+		// Songs without an image are then sent as text alone.
+		log.Printf("Lyric cards are disabled: %v", err)
+	}
+
+	store, states, closeStore, err := openStores()
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer client.Disconnect(context.TODO())
+	defer closeStore()
+	userStates = states
 
-	collection := client.Database("lyrics_bot").Collection("lyrics")
+	images, err = openImageStore()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	if telegramBotToken == "" {
 		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is not set")
@@ -76,6 +74,9 @@ func main() {
 		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 			fmt.Fprintf(w, "Bot is running!")
 		})
+		if local, ok := images.(*LocalImageStore); ok {
+			http.Handle("/images/", local.Handler())
+		}
 		log.Printf("Starting server on port %s", port)
 		if err := http.ListenAndServe(":"+port, nil); err != nil {
 			log.Fatal(err)
@@ -92,14 +93,86 @@ func main() {
 
 	for update := range updates {
 		if update.Message != nil {
-			handleUpdate(bot, update, collection)
+			handleUpdate(bot, update, store)
 		} else if update.CallbackQuery != nil {
-			handleCallbackQuery(bot, update.CallbackQuery, collection)
+			handleCallbackQuery(bot, update.CallbackQuery, store)
+		}
+	}
+}
+
+// openStores opens the configured backend, indexes its lyrics, and opens
+// the conversation state store kept alongside it.
+func openStores() (SongStore, StateStore, func(), error) {
+	ttl := defaultStateTTL
+	if v := os.Getenv("STATE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid STATE_TTL %q: %w", v, err)
 		}
+		ttl = d
+	}
+
+	backend, states, closeStore, err := openBackend(ttl)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	store, err := NewIndexedSongStore(backend)
+	if err != nil {
+		closeStore()
+		return nil, nil, nil, err
 	}
+	return store, states, closeStore, nil
 }
 
-func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mongo.Collection) {
+// openBackend builds the SongStore and StateStore selected by STORE_BACKEND:
+// "mongo" (the default, using MONGODB_URI), "memory", or "file" (using
+// SONGS_FILE and STATES_FILE). States idle for longer than stateTTL expire.
+func openBackend(stateTTL time.Duration) (SongStore, StateStore, func(), error) {
+	switch backend := os.Getenv("STORE_BACKEND"); backend {
+	case "", "mongo":
+		client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(os.Getenv("MONGODB_URI")))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		closeStore := func() { client.Disconnect(context.TODO()) }
+		db := client.Database("lyrics_bot")
+		store := NewMongoSongStore(db.Collection("lyrics"))
+		if err := store.EnsureDerivedFields(context.TODO()); err != nil {
+			closeStore()
+			return nil, nil, nil, err
+		}
+		states := NewMongoStateStore(db.Collection("states"), stateTTL)
+		if err := states.EnsureExpiryIndex(context.TODO()); err != nil {
+			// Get still checks expiry, so the bot can run without the index.
+			log.Printf("Failed to create state expiry index: %v", err)
+		}
+		return store, states, closeStore, nil
+	case "memory":
+		return NewMemorySongStore(), NewMemoryStateStore(stateTTL), func() {}, nil
+	case "file":
+		path := os.Getenv("SONGS_FILE")
+		if path == "" {
+			path = "songs.json"
+		}
+		store, err := NewFileSongStore(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		statesPath := os.Getenv("STATES_FILE")
+		if statesPath == "" {
+			statesPath = "states.json"
+		}
+		states, err := NewFileStateStore(statesPath, stateTTL)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		return store, states, func() {}, nil
+	default:
+		return nil, nil, nil, fmt.Errorf("unknown STORE_BACKEND %q", backend)
+	}
+}
+
+func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, store SongStore) {
 	if update.Message == nil {
 		return
 	}
@@ -109,13 +182,19 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 		switch update.Message.Command() {
 		case "start":
 			sendMainMenu(bot, update.Message.Chat.ID)
+			if _, exists := getState(stateKey(update.Message)); exists {
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID,
+					"You have an unfinished song in progress. Send your next answer to carry on where you left off, or /cancel to discard it."))
+			}
 		case "help":
 			helpCommand(bot, update.Message)
 		case "lyrics":
-			lyricsCommand(bot, update.Message, collection)
+			lyricsCommand(bot, update.Message, store)
+		case "find":
+			findCommand(bot, update.Message, store)
 		case "addsong":
 			if isAdmin(update.Message.From.ID) {
-				addSongCommand(bot, update.Message, collection)
+				addSongCommand(bot, update.Message, store)
 			} else {
 				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "You are not authorized to add songs."))
 			}
@@ -125,15 +204,33 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 			} else {
 				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "You are not authorized to upload images."))
 			}
+		case "addpage", "pages", "movepage", "removepage":
+			if !isAdmin(update.Message.From.ID) {
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, "You are not authorized to manage song pages."))
+				return
+			}
+			switch update.Message.Command() {
+			case "addpage":
+				addPageCommand(bot, update.Message, store)
+			case "pages":
+				pagesCommand(bot, update.Message, store)
+			case "movepage":
+				movePageCommand(bot, update.Message, store)
+			case "removepage":
+				removePageCommand(bot, update.Message, store)
+			}
+		case "done":
+			if state, exists := getState(stateKey(update.Message)); exists && state.Stage == "awaiting_pages" {
+				clearState(stateKey(update.Message))
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID,
+					fmt.Sprintf("Finished adding pages to %q.", state.Title)))
+			}
 		case "cancel":
-			if _, exists := userStates[update.Message.From.ID]; exists {
-				delete(userStates, update.Message.From.ID)
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-					"Song addition cancelled.")
-				bot.Send(msg)
+			if _, exists := getState(stateKey(update.Message)); exists {
+				cancelWizard(bot, update.Message)
 			}
 		default:
-			defaultMessage(bot, update.Message, collection)
+			defaultMessage(bot, update.Message, store)
 		}
 		return
 	}
@@ -142,13 +239,11 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 	switch update.Message.Text {
 	case "🎵 Search Lyrics":
 		msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-			"Please enter a letter (A-Z) to see available songs, or use /lyrics <song title> to search directly.")
+			"Please enter a letter (ሀ–ፐ or A-Z) to see available songs, or use /lyrics <song title> to search directly.")
 		bot.Send(msg)
 
 	case "📝 View All Songs":
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-			"Please select a letter (A-Z) to see songs starting with that letter:")
-		bot.Send(msg)
+		sendLetterIndex(bot, update.Message.Chat.ID)
 
 	case "⬆️ Upload Image":
 		if isAdmin(update.Message.From.ID) {
@@ -163,10 +258,7 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 
 	case "➕ Add Song":
 		if isAdmin(update.Message.From.ID) {
-			userStates[update.Message.From.ID] = UserState{Stage: "awaiting_title"}
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-				"Please enter the song title:\n(or type /cancel to abort)")
-			bot.Send(msg)
+			startWizard(bot, update.Message, addSongWizard)
 		} else {
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
 				"You are not authorized to add songs.")
@@ -177,22 +269,26 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 		helpText := "Welcome to Maranatha Choir Lyrics Bot! 🎵\n\n" +
 			"📱 Main Features:\n" +
 			"🔍 Search Lyrics - Search for song lyrics by title\n" +
-			"📝 View All Songs - Browse all songs alphabetically\n" +
+			"📝 View All Songs - Browse all songs by Ge'ez or Latin letter\n" +
 			"👥 Choir Songs - View songs specific to choir\n" +
 			"🎵 Non-Choir Songs - View other spiritual songs\n" +
 			"🎲 Random Song - Get a random song from our collection\n\n" +
 			"👨‍💼 Admin Features:\n" +
 			"⬆️ Upload Image - Upload images for songs\n" +
 			"➕ Add Song - Add new songs to the database\n" +
-			"✏️ Edit Song - Modify existing songs\n\n" +
+			"✏️ Edit Song - Modify existing songs\n" +
+			"📄 /addpage <title> - Add photo or PDF pages to a song\n" +
+			"📄 /pages <title> - List, reorder or remove a song's pages\n\n" +
 			"🔍 Search Tips:\n" +
 			"• Use /lyrics <song title> to search directly\n" +
+			"• Use /find <words> to search inside the lyrics\n" +
 			"• Browse songs alphabetically by clicking letters\n" +
 			"• Use the category buttons for filtered views\n\n" +
 			"📜 Commands:\n" +
 			"/start - Show main menu\n" +
 			"/help - Show this help message\n" +
 			"/lyrics <title> - Get lyrics for a specific song\n" +
+			"/find <words> - Find songs containing a line\n" +
 			"/cancel - Cancel current operation\n\n" +
 			"For any issues or song requests, please contact the administrators."
 
@@ -200,20 +296,14 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 		bot.Send(msg)
 
 	case "👥 Choir Songs":
-		showSongsByCategory(bot, update.Message, collection, "Choir")
+		showSongsByCategory(bot, update.Message, store, "Choir")
 
 	case "🎵 Non-Choir Songs":
-		showSongsByCategory(bot, update.Message, collection, "Non-Choir")
+		showSongsByCategory(bot, update.Message, store, "Non-Choir")
 
 	case "✏️ Edit Song":
 		if isAdmin(update.Message.From.ID) {
-			userStates[update.Message.From.ID] = UserState{
-				Stage:     "edit_select_song",
-				IsEditing: true,
-			}
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-				"Please enter the title of the song you want to edit:")
-			bot.Send(msg)
+			startWizard(bot, update.Message, editSongWizard)
 		} else {
 			msg := tgbotapi.NewMessage(update.Message.Chat.ID,
 				"You are not authorized to edit songs.")
@@ -221,352 +311,248 @@ func handleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, collection *mong
 		}
 
 	case "🎲 Random Song":
-		getRandomSong(bot, update.Message, collection)
+		getRandomSong(bot, update.Message, store)
 
 	default:
 		if isAdmin(update.Message.From.ID) {
-			if state, exists := userStates[update.Message.From.ID]; exists {
-				switch state.Stage {
-				case "awaiting_title":
-					userStates[update.Message.From.ID] = UserState{
-						Stage: "awaiting_category",
-						Title: update.Message.Text,
-					}
-					keyboard := tgbotapi.NewReplyKeyboard(
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Choir"),
-							tgbotapi.NewKeyboardButton("Non-Choir"),
-						),
-					)
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-						"Please select the song category:")
-					msg.ReplyMarkup = keyboard
-					bot.Send(msg)
-					return
-
-				case "awaiting_category":
-					if update.Message.Text != "Choir" && update.Message.Text != "Non-Choir" {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Please select a valid category (Choir/Non-Choir):")
-						bot.Send(msg)
-						return
-					}
-					userStates[update.Message.From.ID] = UserState{
-						Stage:    "awaiting_lyrics",
-						Title:    state.Title,
-						Category: update.Message.Text,
-					}
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Great! Now please enter the lyrics:")
-					msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
-					bot.Send(msg)
-					return
-
-				case "awaiting_lyrics":
-					userStates[update.Message.From.ID] = UserState{
-						Stage:    "awaiting_image",
-						Title:    state.Title,
-						Category: state.Category,
-						Lyrics:   update.Message.Text,
-					}
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-						"Perfect! Now please send the image URL or upload an image:")
-					bot.Send(msg)
-					return
-
-				case "awaiting_image":
-					var imageURL string
-
-					// Check if message contains a photo
-					if update.Message.Photo != nil {
-						// Get the highest resolution photo
-						photo := (*update.Message.Photo)[len(*update.Message.Photo)-1]
-						fileURL, err := bot.GetFileDirectURL(photo.FileID)
-						if err != nil {
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Failed to process image.")
-							bot.Send(msg)
-							return
-						}
-
-						// Download and upload to Imgur
-						imagePath := "temp_image.jpg"
-						err = downloadFile(imagePath, fileURL)
-						if err != nil {
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Failed to download image.")
-							bot.Send(msg)
-							return
-						}
-						defer os.Remove(imagePath)
-
-						imgurURL, err := uploadImageToImgur(imagePath)
-						if err != nil {
-							msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Failed to upload image to Imgur.")
-							bot.Send(msg)
-							return
-						}
-						imageURL = imgurURL
-					} else {
-						// Use the text as URL directly
-						imageURL = update.Message.Text
-					}
-
-					// Insert the song with the image URL
-					_, err := collection.InsertOne(context.TODO(), bson.M{
-						"title":    state.Title,
-						"lyrics":   state.Lyrics,
-						"image":    imageURL,
-						"category": state.Category,
-					})
-
-					if err != nil {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Failed to add song.")
-						bot.Send(msg)
-					} else {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Song added successfully!")
-						bot.Send(msg)
-					}
-					delete(userStates, update.Message.From.ID)
+			if state, exists := getState(stateKey(update.Message)); exists && continuesConversation(update.Message, state) {
+				switch {
+				case state.Wizard != "":
+					handleWizardMessage(bot, store, update.Message, state)
 					return
-
-				case "edit_select_song":
-					// Find the song first
-					var result bson.M
-					err := collection.FindOne(context.TODO(), bson.M{"title": update.Message.Text}).Decode(&result)
-					if err != nil {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Song not found. Please try again:")
-						bot.Send(msg)
-						return
-					}
-
-					// Store the title for later use
-					userStates[update.Message.From.ID] = UserState{
-						Stage:     "edit_select_field",
-						Title:     update.Message.Text,
-						IsEditing: true,
-					}
-
-					// Create keyboard for edit options
-					keyboard := tgbotapi.NewReplyKeyboard(
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Edit Title"),
-							tgbotapi.NewKeyboardButton("Edit Lyrics"),
-						),
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Edit Category"),
-							tgbotapi.NewKeyboardButton("Edit Image"),
-						),
-						tgbotapi.NewKeyboardButtonRow(
-							tgbotapi.NewKeyboardButton("Cancel"),
-						),
-					)
-
-					msg := tgbotapi.NewMessage(update.Message.Chat.ID, "What would you like to edit?")
-					msg.ReplyMarkup = keyboard
-					bot.Send(msg)
-					return
-
-				case "edit_select_field":
-					switch update.Message.Text {
-					case "Edit Title", "Edit Lyrics", "Edit Category", "Edit Image":
-						userStates[update.Message.From.ID] = UserState{
-							Stage:     "edit_enter_value",
-							Title:     state.Title,
-							IsEditing: true,
-							EditField: strings.ToLower(strings.Split(update.Message.Text, " ")[1]),
-						}
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID,
-							fmt.Sprintf("Please enter the new %s:",
-								strings.ToLower(strings.Split(update.Message.Text, " ")[1])))
-						msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
-						bot.Send(msg)
-						return
-					case "Cancel":
-						delete(userStates, update.Message.From.ID)
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Edit cancelled.")
-						msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
-						bot.Send(msg)
-						sendMainMenu(bot, update.Message.Chat.ID)
-						return
-					}
-
-				case "edit_enter_value":
-					// Update the document in MongoDB
-					filter := bson.M{"title": state.Title}
-					updateDoc := bson.M{"$set": bson.M{state.EditField: update.Message.Text}}
-
-					_, err := collection.UpdateOne(context.TODO(), filter, updateDoc)
-					if err != nil {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Failed to update the song.")
-						bot.Send(msg)
-					} else {
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Song updated successfully!")
-						bot.Send(msg)
-					}
-
-					delete(userStates, update.Message.From.ID)
-					sendMainMenu(bot, update.Message.Chat.ID)
+				case state.Stage == "awaiting_pages":
+					addPage(bot, update.Message, store, state)
 					return
 				}
 			}
 		}
-		handleAlphabetSelection(bot, update.Message, collection)
+		handleAlphabetSelection(bot, update.Message, store)
 	}
 }
 
-func lyricsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection) {
-	songTitle := message.CommandArguments()
-	lyrics, imageURL, exists := getLyricsFromDB(collection, songTitle)
-	if exists {
-		// Send the image
-		photoMsg := tgbotapi.NewPhotoShare(message.Chat.ID, imageURL)
-		bot.Send(photoMsg)
+func findCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	phrase := strings.TrimSpace(message.CommandArguments())
+	if phrase == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Usage: /find <words from the lyrics>"))
+		return
+	}
 
-		// Send the lyrics
-		lyricsMsg := tgbotapi.NewMessage(message.Chat.ID, lyrics)
-		bot.Send(lyricsMsg)
-	} else {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Sorry, I couldn't find the lyrics for that song."))
+	matches := searchLyrics(store, phrase)
+	if len(matches) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Sorry, no song contains those words."))
+		return
 	}
+	sendLyricsResults(bot, message.Chat.ID, phrase, matches)
 }
 
-func getLyricsFromDB(collection *mongo.Collection, title string) (string, string, bool) {
-	var result bson.M
-	err := collection.FindOne(context.TODO(), bson.M{"title": title}).Decode(&result)
-	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return "", "", false
-		}
-		log.Printf("Failed to query lyrics: %v", err)
-		return "", "", false
+func lyricsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	songTitle := message.CommandArguments()
+	song, exists := findSong(store, songTitle)
+	if exists {
+		sendSong(bot, store, message.Chat.ID, song)
+	} else {
+		sendSearchResults(bot, message.Chat.ID, "I couldn't find that exact title. Did you mean:",
+			searchTitles(store, songTitle))
 	}
-	lyrics := result["lyrics"].(string)
-	imageURL := result["image"].(string)
-	return lyrics, imageURL, true
 }
 
-func uploadImageCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	if message.Photo == nil {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Please attach an image to upload."))
-		return
-	}
+// sendSong sends a song as a single captioned photo when the rendered lyrics
+// fit in a caption, and otherwise as a photo titled with the song followed by
+// the lyrics. Songs without an image are shown on a generated lyric card; if
+// no photo can be sent the song goes out as text alone, so the lyrics always
+// arrive with their title. Any further pages follow as a media group.
+func sendSong(bot *tgbotapi.BotAPI, store SongStore, chatID int64, song *Song) {
+	text := renderSongHTML(song)
 
-	photo := (*message.Photo)[len(*message.Photo)-1] // Get the highest resolution photo
-	fileURL, err := bot.GetFileDirectURL(photo.FileID)
-	if err != nil {
-		log.Printf("Failed to get file URL: %v", err)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to process image."))
-		return
+	caption := text
+	var markup interface{} = songActions(song)
+	fits := utf8.RuneCountInString(text) <= maxCaptionRunes
+	if !fits {
+		caption, markup = fmt.Sprintf("<b>%s</b>", html.EscapeString(song.Title)), nil
 	}
 
-	imagePath := "temp_image.jpg"
-	err = downloadFile(imagePath, fileURL)
-	if err != nil {
-		log.Printf("Failed to download image: %v", err)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to download image."))
-		return
+	err := sendSongPhoto(bot, store, chatID, song, caption, markup)
+	if err != nil && err != errCardGlyphs && err != errCardsDisabled {
+		log.Printf("Failed to send image for %q: %v", song.Title, err)
 	}
-	defer os.Remove(imagePath) // Clean up the downloaded file
-
-	imgurLink, err := uploadImageToImgur(imagePath)
-	if err != nil {
-		log.Printf("Failed to upload image to Imgur: %v", err)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to upload image to Imgur."))
+	if err == nil && fits {
+		sendAttachments(bot, store, chatID, song)
 		return
 	}
 
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Image uploaded successfully: %s", imgurLink)))
+	// Send the song, split if it exceeds Telegram's message limit
+	sendLongText(bot, chatID, text, tgbotapi.ModeHTML, songActions(song))
+	sendAttachments(bot, store, chatID, song)
 }
 
-func addSongCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection) {
-	args := strings.SplitN(message.CommandArguments(), "|", 3)
-	if len(args) != 3 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Usage: /addsong <title>|<lyrics>|<image_url>"))
-		return
+// sendSongPhoto sends the song image with an HTML caption. It uses the cached
+// Telegram file_id when there is one, falls back to the image URL if Telegram
+// rejects it, and caches the file_id returned by a successful URL send. Songs
+// without a working image get their lyric card instead.
+func sendSongPhoto(bot *tgbotapi.BotAPI, store SongStore, chatID int64, song *Song, caption string, markup interface{}) error {
+	if song.Image == "" && song.ImageFileID == "" {
+		return sendLyricCard(bot, chatID, song, caption, markup)
 	}
 
-	title := strings.TrimSpace(args[0])
-	lyrics := strings.TrimSpace(args[1])
-	imageURL := strings.TrimSpace(args[2])
+	photo := func(file string) tgbotapi.PhotoConfig {
+		msg := tgbotapi.NewPhotoShare(chatID, file)
+		msg.Caption = caption
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyMarkup = markup
+		return msg
+	}
 
-	_, err := collection.InsertOne(context.TODO(), bson.M{
-		"title":  title,
-		"lyrics": lyrics,
-		"image":  imageURL,
-	})
-	if err != nil {
-		log.Printf("Failed to insert song: %v", err)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to add song."))
-		return
+	if song.ImageFileID != "" {
+		_, err := bot.Send(photo(song.ImageFileID))
+		if err == nil || song.Image == "" {
+			return err
+		}
+		log.Printf("Cached image for %q failed, refetching from URL: %v", song.Title, err)
 	}
 
-	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song added successfully!"))
+	sent, err := bot.Send(photo(images.URL(song.Image)))
+	if err != nil {
+		// Placeholder or dead links are common; the card still shows the song.
+		log.Printf("Image for %q failed, sending its lyric card: %v", song.Title, err)
+		return sendLyricCard(bot, chatID, song, caption, markup)
+	}
+	if sent.Photo == nil || len(*sent.Photo) == 0 {
+		return nil
+	}
+	// Telegram returns every size it generated; the last is the largest.
+	fileID := (*sent.Photo)[len(*sent.Photo)-1].FileID
+	if fileID != song.ImageFileID {
+		song.ImageFileID = fileID
+		if err := store.SetImageFileID(context.TODO(), song.ID, fileID); err != nil {
+			log.Printf("Failed to cache image file_id for %q: %v", song.Title, err)
+		}
+	}
+	return nil
 }
 
-func downloadFile(filepath string, url string) error {
-	resp, err := http.Get(url)
+// findSong looks a song up by title, logging any store error other than a miss.
+func findSong(store SongStore, title string) (*Song, bool) {
+	song, err := store.FindByTitle(context.TODO(), title)
 	if err != nil {
-		return err
+		if err != ErrSongNotFound {
+			log.Printf("Failed to query lyrics: %v", err)
+		}
+		return nil, false
 	}
-	defer resp.Body.Close()
+	return song, true
+}
 
-	out, err := os.Create(filepath)
+// updateSongField sets one editable field (title, lyrics, category, image,
+// composer or key) on the song with the given ID.
+func updateSongField(store SongStore, id, field, value string) error {
+	song, err := store.Get(context.TODO(), id)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	oldImage := song.Image
+	switch field {
+	case "title":
+		song.Title = value
+	case "lyrics":
+		song.Lyrics = value
+	case "category":
+		song.Category = value
+	case "image":
+		song.Image = value
+		song.ImageFileID = ""
+	case "composer":
+		song.Composer = value
+	case "key":
+		song.Key = value
+	default:
+		return fmt.Errorf("unknown song field %q", field)
+	}
+	if err := store.Update(context.TODO(), song); err != nil {
+		return err
+	}
+	if oldImage != "" && oldImage != song.Image {
+		deleteImage(oldImage)
+	}
+	return nil
 }
 
-func uploadImageToImgur(imagePath string) (string, error) {
-	file, err := os.Open(imagePath)
-	if err != nil {
-		return "", err
+func uploadImageCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if message.Photo == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Please attach an image to upload."))
+		return
 	}
-	defer file.Close()
 
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
-	part, err := writer.CreateFormFile("image", file.Name())
-	if err != nil {
-		return "", err
-	}
-	_, err = io.Copy(part, file)
+	photo := (*message.Photo)[len(*message.Photo)-1] // Get the highest resolution photo
+	ref, err := images.Put(context.TODO(), telegramImage(bot, photo))
 	if err != nil {
-		return "", err
+		log.Printf("Failed to store image: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, imageErrorText(err)))
+		return
 	}
-	writer.Close()
 
-	req, err := http.NewRequest("POST", "https://api.imgur.com/3/upload", &requestBody)
-	if err != nil {
-		return "", err
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Image uploaded successfully: %s", images.URL(ref))))
+}
+
+func addSongCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	args := strings.SplitN(message.CommandArguments(), "|", 3)
+	if len(args) != 3 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Usage: /addsong <title>|<lyrics>|<image_url>"))
+		return
 	}
-	req.Header.Set("Authorization", "Client-ID "+os.Getenv("IMGUR_CLIENT_ID"))
-	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	song := &Song{
+		Title:  strings.TrimSpace(args[0]),
+		Lyrics: strings.TrimSpace(args[1]),
+		Image:  strings.TrimSpace(args[2]),
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	err := store.Insert(context.TODO(), song)
 	if err != nil {
-		return "", err
+		log.Printf("Failed to insert song: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Failed to add song."))
+		return
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
-	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Song added successfully!"))
+}
 
-	if data, ok := result["data"].(map[string]interface{}); ok {
-		if link, ok := data["link"].(string); ok {
-			return link, nil
-		}
+// telegramImage describes a photo sent to the bot, streaming it from Telegram
+// only if the image store asks for its bytes. Nothing is written to a shared
+// path, so concurrent uploads by several admins cannot collide.
+func telegramImage(bot *tgbotapi.BotAPI, photo tgbotapi.PhotoSize) Image {
+	fileID := photo.FileID
+	return Image{
+		FileID: fileID,
+		Open: func() (io.ReadCloser, error) {
+			if photo.FileSize > maxImageBytes {
+				return nil, ErrImageTooLarge
+			}
+			fileURL, err := bot.GetFileDirectURL(fileID)
+			if err != nil {
+				return nil, err
+			}
+			resp, err := http.Get(fileURL)
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return nil, fmt.Errorf("failed to download image: status %d", resp.StatusCode)
+			}
+			return resp.Body, nil
+		},
 	}
+}
 
-	return "", fmt.Errorf("failed to upload image: %v", result)
+// imageErrorText explains a failed upload to the admin.
+func imageErrorText(err error) string {
+	switch {
+	case errors.Is(err, ErrImageTooLarge):
+		return fmt.Sprintf("Failed to upload image: %v.", err)
+	case errors.Is(err, ErrUnsupportedImage):
+		return "Failed to upload image: please send a JPEG, PNG or WebP image."
+	}
+	return "Failed to upload image."
 }
 
 func isAdmin(userID int) bool {
@@ -579,103 +565,36 @@ func isAdmin(userID int) bool {
 }
 
 func helpCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Here are some commands you can use:\n/start - Start the bot\n/help - Get help information\n/lyrics <song title> - Get lyrics for a song")
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Here are some commands you can use:\n/start - Start the bot\n/help - Get help information\n/lyrics <song title> - Get lyrics for a song\n/find <words> - Find songs containing those words")
 	bot.Send(msg)
 }
 
-func defaultMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection) {
-	responseText := fmt.Sprintf("You said: %s", message.Text)
-	suggestions := getSuggestions(collection, message.Text)
-	if len(suggestions) > 0 {
-		responseText += "\nDid you mean:\n" + strings.Join(suggestions, "\n")
+func defaultMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	// An unknown command is most often a title typed after a slash.
+	results := searchTitles(store, strings.TrimPrefix(message.Text, "/"))
+	if len(results) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID,
+			"Sorry, I don't know that command. Use /help to see what I can do."))
+		return
 	}
-	msg := tgbotapi.NewMessage(message.Chat.ID, responseText)
-	bot.Send(msg)
+	sendSearchResults(bot, message.Chat.ID, "Did you mean:", results)
 }
 
-func getSuggestions(collection *mongo.Collection, input string) []string {
-	var matches []string
-	cursor, err := collection.Find(context.TODO(), bson.M{"title": bson.M{"$regex": "^" + input, "$options": "i"}})
-	if err != nil {
-		log.Printf("Failed to query suggestions: %v", err)
-		return nil
-	}
-	defer cursor.Close(context.TODO())
-
-	for cursor.Next(context.TODO()) {
-		var result bson.M
-		if err := cursor.Decode(&result); err != nil {
-			log.Printf("Failed to decode result: %v", err)
-			return nil
+func handleAlphabetSelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	letter, ok := parseLetter(message.Text)
+	if !ok {
+		// Anything longer than a letter is searched for in titles and lyrics.
+		titles := searchTitles(store, message.Text)
+		matches := searchLyrics(store, message.Text)
+		if len(titles) > 0 || len(matches) == 0 {
+			sendSearchResults(bot, message.Chat.ID, "Select a song to get the lyrics:", titles)
 		}
-		matches = append(matches, result["title"].(string))
-	}
-	return matches
-}
-
-func handleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, collection *mongo.Collection) {
-	switch callbackQuery.Data {
-	case "popular_series", "new_series", "popular_movies", "new_movies", "popular_anime", "new_anime":
-		// Handle the category selection
-		msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID,
-			fmt.Sprintf("You selected: %s\nThis feature is coming soon!", callbackQuery.Data))
-		bot.Send(msg)
-	default:
-		// Handle existing song selection logic
-		songTitle := callbackQuery.Data
-		lyrics, imageURL, exists := getLyricsFromDB(collection, songTitle)
-		if exists {
-			photoMsg := tgbotapi.NewPhotoShare(callbackQuery.Message.Chat.ID, imageURL)
-			bot.Send(photoMsg)
-
-			msg := tgbotapi.NewMessage(callbackQuery.Message.Chat.ID, lyrics)
-			bot.Send(msg)
-		} else {
-			bot.Send(tgbotapi.NewMessage(callbackQuery.Message.Chat.ID,
-				"Sorry, I couldn't find the lyrics for that song."))
+		if len(matches) > 0 {
+			sendLyricsResults(bot, message.Chat.ID, message.Text, matches)
 		}
-	}
-
-	bot.AnswerCallbackQuery(tgbotapi.NewCallback(callbackQuery.ID, ""))
-}
-
-func handleAlphabetSelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection) {
-	alphabet := strings.ToUpper(message.Text)
-	if len(alphabet) != 1 || alphabet < "A" || alphabet > "Z" {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Please select a valid alphabet (A-Z)."))
-		return
-	}
-
-	var songs []string
-	cursor, err := collection.Find(context.TODO(), bson.M{"title": bson.M{"$regex": "^" + alphabet, "$options": "i"}})
-	if err != nil {
-		log.Printf("Failed to query songs: %v", err)
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	for cursor.Next(context.TODO()) {
-		var result bson.M
-		if err := cursor.Decode(&result); err != nil {
-			log.Printf("Failed to decode result: %v", err)
-			return
-		}
-		songs = append(songs, result["title"].(string))
-	}
-
-	if len(songs) == 0 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("No songs found starting with %s.", alphabet)))
-	} else {
-		var buttons []tgbotapi.InlineKeyboardButton
-		for _, song := range songs {
-			buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(song, song))
-		}
-
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "Select a song to get the lyrics:")
-		msg.ReplyMarkup = keyboard
-		bot.Send(msg)
-	}
+	showSongsByLetter(bot, message.Chat.ID, store, letter)
 }
 
 func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) {
@@ -706,23 +625,12 @@ func sendMainMenu(bot *tgbotapi.BotAPI, chatID int64) {
 	bot.Send(msg)
 }
 
-func showSongsByCategory(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection, category string) {
-	cursor, err := collection.Find(context.TODO(), bson.M{"category": category})
+func showSongsByCategory(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore, category string) {
+	songs, err := store.ListByCategory(context.TODO(), category)
 	if err != nil {
 		log.Printf("Failed to query songs: %v", err)
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var songs []string
-	for cursor.Next(context.TODO()) {
-		var result bson.M
-		if err := cursor.Decode(&result); err != nil {
-			log.Printf("Failed to decode result: %v", err)
-			return
-		}
-		songs = append(songs, result["title"].(string))
-	}
 
 	if len(songs) == 0 {
 		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("No %s songs found.", category))
@@ -730,66 +638,23 @@ func showSongsByCategory(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collec
 		return
 	}
 
-	var buttons []tgbotapi.InlineKeyboardButton
-	for _, song := range songs {
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(song, song))
-	}
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons)
-	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Select a %s song:", category))
-	msg.ReplyMarkup = keyboard
-	bot.Send(msg)
+	sendSongList(bot, message.Chat.ID, fmt.Sprintf("Select a %s song:", category),
+		fitList(categoryList(category), songs), songs)
 }
 
-func getRandomSong(bot *tgbotapi.BotAPI, message *tgbotapi.Message, collection *mongo.Collection) {
-	pipeline := []bson.M{{"$sample": bson.M{"size": 1}}}
-	cursor, err := collection.Aggregate(context.TODO(), pipeline)
+func getRandomSong(bot *tgbotapi.BotAPI, message *tgbotapi.Message, store SongStore) {
+	song, err := store.Random(context.TODO())
+	if err == ErrSongNotFound {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "No songs found in the database.")
+		bot.Send(msg)
+		return
+	}
 	if err != nil {
+		log.Printf("Failed to get random song: %v", err)
 		msg := tgbotapi.NewMessage(message.Chat.ID, "Failed to get random song.")
 		bot.Send(msg)
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	var result bson.M
-	if cursor.Next(context.TODO()) {
-		if err := cursor.Decode(&result); err != nil {
-			msg := tgbotapi.NewMessage(message.Chat.ID, "Failed to process random song.")
-			bot.Send(msg)
-			return
-		}
-
-		// Safely get values with nil checks
-		title := ""
-		if t, ok := result["title"].(string); ok {
-			title = t
-		}
 
-		category := ""
-		if c, ok := result["category"].(string); ok {
-			category = c
-		}
-
-		lyrics := ""
-		if l, ok := result["lyrics"].(string); ok {
-			lyrics = l
-		}
-
-		// Send the image if it exists
-		if imageURL, ok := result["image"].(string); ok && imageURL != "" {
-			photoMsg := tgbotapi.NewPhotoShare(message.Chat.ID, imageURL)
-			bot.Send(photoMsg)
-		}
-
-		// Send song details
-		songInfo := fmt.Sprintf("Title: %s\nCategory: %s\n\nLyrics:\n%s",
-			title,
-			category,
-			lyrics)
-		msg := tgbotapi.NewMessage(message.Chat.ID, songInfo)
-		bot.Send(msg)
-	} else {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "No songs found in the database.")
-		bot.Send(msg)
-	}
+	sendSong(bot, store, message.Chat.ID, song)
 }
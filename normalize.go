@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fidelHomophones maps consonant families that are pronounced the same in
+// Amharic onto the one we normalize to. Spelling between them varies from
+// songbook to songbook, so ሐ, ኀ and ሀ must all match.
+var fidelHomophones = map[rune]rune{
+	'ሐ': 'ሀ',
+	'ኀ': 'ሀ',
+	'ሠ': 'ሰ',
+	'ፀ': 'ጸ',
+	'ዐ': 'አ',
+}
+
+// normalizeFidel folds an Ethiopic syllable onto its canonical homophone,
+// keeping the vowel order. The fourth order of ሀ and አ (ሃ, ኣ) is read the
+// same as the first, so it is folded too.
+func normalizeFidel(r rune) rune {
+	base := fidelBase(r)
+	order := r - base
+	if canonical, ok := fidelHomophones[base]; ok {
+		base = canonical
+	}
+	if order == 3 && (base == 'ሀ' || base == 'አ') {
+		order = 0
+	}
+	return base + order
+}
+
+// isEthiopicPunct reports whether r is Ethiopic punctuation such as ፡ ። ፣ ፤.
+func isEthiopicPunct(r rune) bool {
+	return r >= 0x1360 && r <= 0x1368
+}
+
+// normalizeTitle returns the search key for a title: Ethiopic homophones
+// folded, punctuation removed, Latin letters lower-cased and whitespace
+// collapsed. Two titles that differ only in those respects share a key.
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range title {
+		switch {
+		case isFidel(r):
+			r = normalizeFidel(r)
+		case r == '\'' || r == '’':
+			continue
+		case isEthiopicPunct(r), unicode.IsPunct(r), unicode.IsSymbol(r):
+			r = ' '
+		default:
+			r = unicode.ToLower(r)
+		}
+
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	callbackPageV1 = "page:v1"
+	callbackNoopV1 = "noop:v1"
+)
+
+// Song list specs identify what a paginated keyboard is showing so the next
+// page can be rebuilt from the callback data alone.
+const (
+	listCategory = "c" // c:<category>
+	listLetter   = "l" // l:<index letter>, see songsByLetter
+	listResults  = "r" // r:<token> into songResults
+)
+
+// maxCallbackData is Telegram's limit on InlineKeyboardButton callback data.
+const maxCallbackData = 64
+
+var (
+	// pageSize is the number of songs shown per page (PAGE_SIZE).
+	pageSize = 8
+	// pageColumns is the number of song buttons per row, 1 or 2 (PAGE_COLUMNS).
+	pageColumns = 1
+)
+
+// configurePagination reads PAGE_SIZE and PAGE_COLUMNS from the environment.
+func configurePagination(size, columns string) {
+	if n, err := strconv.Atoi(size); err == nil && n > 0 {
+		pageSize = n
+	}
+	if n, err := strconv.Atoi(columns); err == nil && (n == 1 || n == 2) {
+		pageColumns = n
+	}
+}
+
+func categoryList(category string) string { return listCategory + ":" + category }
+
+func letterList(letter string) string { return listLetter + ":" + letter }
+
+// resultsList stashes an already-computed list of songs, such as ranked search
+// results, and returns a short spec that refers to it.
+func resultsList(songs []Song) string {
+	return listResults + ":" + songResults.put(songs)
+}
+
+// fitList returns spec if a page callback for it fits in Telegram's limit, and
+// otherwise falls back to stashing songs under a short token.
+func fitList(spec string, songs []Song) string {
+	if len(pageCallbackData(spec, 9999)) <= maxCallbackData {
+		return spec
+	}
+	return resultsList(songs)
+}
+
+func pageCallbackData(list string, page int) string {
+	return fmt.Sprintf("%s:%d:%s", callbackPageV1, page, list)
+}
+
+// loadSongList re-runs the query described by list.
+func loadSongList(store SongStore, list string) ([]Song, error) {
+	kind, key, _ := strings.Cut(list, ":")
+	switch kind {
+	case listCategory:
+		return store.ListByCategory(context.TODO(), key)
+	case listLetter:
+		return songsByLetter(store, key)
+	case listResults:
+		songs, ok := songResults.get(key)
+		if !ok {
+			return nil, errListExpired
+		}
+		return songs, nil
+	default:
+		return nil, fmt.Errorf("unknown song list %q", list)
+	}
+}
+
+var errListExpired = errors.New("song list expired")
+
+// songListKeyboard returns the inline keyboard for one page of songs, with
+// ◀️/▶️ navigation when there is more than one page.
+func songListKeyboard(list string, songs []Song, page int) tgbotapi.InlineKeyboardMarkup {
+	pages := (len(songs) + pageSize - 1) / pageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+
+	start := page * pageSize
+	end := start + pageSize
+	if end > len(songs) {
+		end = len(songs)
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, song := range songs[start:end] {
+		row = append(row, songButton(song))
+		if len(row) == pageColumns {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", pageCallbackData(list, page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("%d/%d", page+1, pages), callbackNoopV1+":"))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", pageCallbackData(list, page+1)))
+		}
+		rows = append(rows, nav)
+	}
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// sendSongList sends text with the first page of songs attached.
+func sendSongList(bot *tgbotapi.BotAPI, chatID int64, text, list string, songs []Song) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = songListKeyboard(list, songs, 0)
+	bot.Send(msg)
+}
+
+// handlePageCallback swaps the keyboard of the pressed message for the
+// requested page. The payload is "<page>:<list>".
+func handlePageCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, store SongStore, payload string) {
+	pageStr, list, _ := strings.Cut(payload, ":")
+	page, err := strconv.Atoi(pageStr)
+	if err != nil {
+		log.Printf("Invalid page callback %q", payload)
+		return
+	}
+
+	songs, err := loadSongList(store, list)
+	if err == errListExpired {
+		bot.Send(tgbotapi.NewMessage(query.Message.Chat.ID,
+			"This list has expired. Please search again."))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to load song list %q: %v", list, err)
+		return
+	}
+	if len(songs) == 0 {
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID,
+		songListKeyboard(list, songs, page))
+	if _, err := bot.Send(edit); err != nil {
+		log.Printf("Failed to change page: %v", err)
+	}
+}
+
+// handleNoopCallback acknowledges buttons that only display information, such
+// as the page counter.
+func handleNoopCallback(*tgbotapi.BotAPI, *tgbotapi.CallbackQuery, SongStore, string) {}
+
+// maxSongResults bounds how many stashed result lists are kept in memory.
+const maxSongResults = 500
+
+// resultCache holds result lists that are too large or too expensive to
+// encode in callback data. The oldest entries are dropped first.
+type resultCache struct {
+	mu    sync.Mutex
+	lists map[string][]Song
+	order []string
+}
+
+var songResults = &resultCache{lists: make(map[string][]Song)}
+
+func (c *resultCache) put(songs []Song) string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lists[token] = songs
+	c.order = append(c.order, token)
+	if len(c.order) > maxSongResults {
+		delete(c.lists, c.order[0])
+		c.order = c.order[1:]
+	}
+	return token
+}
+
+func (c *resultCache) get(token string) ([]Song, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	songs, ok := c.lists[token]
+	return songs, ok
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxQueryRunes caps user search input. Titles are far shorter, and the cap
+// bounds the cost of regex and edit-distance matching on hostile input.
+const maxQueryRunes = 100
+
+// capQuery truncates input to maxQueryRunes runes.
+func capQuery(input string) string {
+	runes := []rune(input)
+	if len(runes) > maxQueryRunes {
+		return string(runes[:maxQueryRunes])
+	}
+	return input
+}
+
+// prefixRegex returns a MongoDB regex matching values that start with input
+// literally. User text is never interpreted as a pattern.
+func prefixRegex(input string, caseInsensitive bool) primitive.Regex {
+	re := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(capQuery(input))}
+	if caseInsensitive {
+		re.Options = "i"
+	}
+	return re
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPrefixRegexHostilePatterns(t *testing.T) {
+	tests := []struct {
+		input    string
+		matches  []string
+		rejected []string
+	}{
+		{"(", []string{"(", "(abc"}, []string{"", "abc", "a("}},
+		{".*", []string{".*", ".*abc"}, []string{"", "abc", "a.*"}},
+		{`\`, []string{`\`, `\n`}, []string{"", "n", `a\`}},
+		{"[a-", []string{"[a-", "[a-z]"}, []string{"a", "b", "["}},
+		{"^$|", []string{"^$|", "^$|abc"}, []string{"", "abc", "|"}},
+		{"ጸሎት (", []string{"ጸሎት (", "ጸሎት (ቅዱስ)"}, []string{"ጸሎት", "ጸሎ"}},
+	}
+	for _, tt := range tests {
+		re := prefixRegex(tt.input, false)
+		if !strings.HasPrefix(re.Pattern, "^") {
+			t.Errorf("prefixRegex(%q) = %q, want an anchored pattern", tt.input, re.Pattern)
+		}
+		compiled, err := regexp.Compile(re.Pattern)
+		if err != nil {
+			t.Errorf("prefixRegex(%q) = %q, which does not compile: %v", tt.input, re.Pattern, err)
+			continue
+		}
+		for _, s := range tt.matches {
+			if !compiled.MatchString(s) {
+				t.Errorf("prefixRegex(%q) does not match %q", tt.input, s)
+			}
+		}
+		for _, s := range tt.rejected {
+			if compiled.MatchString(s) {
+				t.Errorf("prefixRegex(%q) matches %q", tt.input, s)
+			}
+		}
+	}
+}
+
+func TestPrefixRegexCaseInsensitive(t *testing.T) {
+	if re := prefixRegex("a.", true); re.Options != "i" {
+		t.Errorf("Options = %q, want %q", re.Options, "i")
+	}
+	if re := prefixRegex("a.", false); re.Options != "" {
+		t.Errorf("Options = %q, want none", re.Options)
+	}
+}
+
+func TestCapQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"short", "ጸሎት", "ጸሎት"},
+		{"exactly the cap", strings.Repeat("a", maxQueryRunes), strings.Repeat("a", maxQueryRunes)},
+		{"ascii over the cap", strings.Repeat("a", maxQueryRunes+50), strings.Repeat("a", maxQueryRunes)},
+		// Ethiopic runes are three bytes, so a byte cut would split one.
+		{"ethiopic over the cap", strings.Repeat("ሀ", maxQueryRunes+1), strings.Repeat("ሀ", maxQueryRunes)},
+		{"mixed widths", "a" + strings.Repeat("ሀ", maxQueryRunes), "a" + strings.Repeat("ሀ", maxQueryRunes-1)},
+	}
+	for _, tt := range tests {
+		got := capQuery(tt.input)
+		if got != tt.want {
+			t.Errorf("%s: capQuery returned %d runes, want %d", tt.name,
+				utf8.RuneCountInString(got), utf8.RuneCountInString(tt.want))
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: capQuery cut inside a rune", tt.name)
+		}
+	}
+}
+
+func TestPrefixRegexTruncatesLongInput(t *testing.T) {
+	input := strings.Repeat("(ሀ", maxQueryRunes)
+	re := prefixRegex(input, false)
+	want := "^" + regexp.QuoteMeta(capQuery(input))
+	if re.Pattern != want {
+		t.Errorf("Pattern = %q, want %q", re.Pattern, want)
+	}
+	compiled := regexp.MustCompile(re.Pattern)
+	if !compiled.MatchString(input) {
+		t.Errorf("truncated pattern does not match the full input")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// renderSongHTML renders a song for Telegram's HTML parse mode: the title in
+// bold, the lyrics with italic section labels, and a footer with whichever
+// of category, composer and key are known. All song text is escaped.
+func renderSongHTML(song *Song) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>%s</b>\n\n", html.EscapeString(song.Title))
+	b.WriteString(renderSectionsHTML(song.Structured()))
+
+	if footer := songFooter(song); footer != "" {
+		fmt.Fprintf(&b, "\n\n<i>%s</i>", html.EscapeString(footer))
+	}
+	return b.String()
+}
+
+// songFooter returns the song's metadata as one line, such as
+// "Choir · Composer: Tekle · Key: G".
+func songFooter(song *Song) string {
+	var parts []string
+	if song.Category != "" {
+		parts = append(parts, song.Category)
+	}
+	if song.Composer != "" {
+		parts = append(parts, "Composer: "+song.Composer)
+	}
+	if song.Key != "" {
+		parts = append(parts, "Key: "+song.Key)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// songActions is the keyboard under a song: follow along, or share the song
+// as a lyric card image when cards are enabled.
+func songActions(song *Song) tgbotapi.InlineKeyboardMarkup {
+	row := tgbotapi.NewInlineKeyboardRow(followButton(song))
+	if _, err := loadCardFont(); err == nil {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🖼 Share as image", callbackCardV1+":"+song.ID))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
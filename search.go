@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// maxSearchResults is the number of ranked candidates offered as buttons.
+const maxSearchResults = 10
+
+// searchResult is a song with its match score; higher is better.
+type searchResult struct {
+	song  Song
+	score int
+}
+
+// searchTitles ranks songs whose titles match query, either directly or, for
+// Latin input, by transliteration of their Ge'ez titles. Titles that only
+// match with typos are included below exact, prefix and substring matches.
+func searchTitles(store SongStore, query string) []Song {
+	query = capQuery(query)
+	key := normalizeTitle(query)
+	if key == "" {
+		return nil
+	}
+
+	songs, err := searchableSongs(store)
+	if err != nil {
+		log.Printf("Failed to load songs for search: %v", err)
+		return nil
+	}
+
+	latin := isLatinText(query)
+	phonetic := phoneticKey(query)
+
+	var results []searchResult
+	for _, song := range songs {
+		score := max(titleScore(key, song.TitleKey), fuzzyScore(key, song.TitleKey))
+		if latin && phonetic != "" {
+			score = max(score, transliterationScore(key, phonetic, song.Title))
+		}
+		if score > 0 {
+			results = append(results, searchResult{song: song, score: score})
+		}
+	}
+
+	return rankResults(results)
+}
+
+// songLister is implemented by stores that hold every song in memory.
+type songLister interface {
+	Songs() []Song
+}
+
+// searchableSongs returns every song, from the store's index when it has
+// one so a search does not load the whole catalogue from the backend.
+func searchableSongs(store SongStore) ([]Song, error) {
+	if lister, ok := store.(songLister); ok {
+		return lister.Songs(), nil
+	}
+	return store.All(context.TODO())
+}
+
+// titleScore scores a normalized query against a normalized title.
+func titleScore(key, title string) int {
+	switch {
+	case title == key:
+		return 100
+	case strings.HasPrefix(title, key):
+		return 80
+	case strings.Contains(title, key):
+		return 60
+	}
+	return 0
+}
+
+// transliterationScore scores Latin input against a Ge'ez title, first by its
+// Latin spelling and then by consonant skeleton.
+func transliterationScore(key, phonetic, title string) int {
+	latin := transliterate(title)
+	if s := titleScore(key, latin); s > 0 {
+		return s - 5
+	}
+	titleKey := phoneticKey(title)
+	switch {
+	case titleKey == phonetic:
+		return 70
+	case strings.HasPrefix(titleKey, phonetic):
+		return 50
+	case len(phonetic) >= 3 && strings.Contains(titleKey, phonetic):
+		return 30
+	}
+	return fuzzyScore(key, latin) / 2
+}
+
+// rankResults orders results by score, then by shorter title, and returns at
+// most maxSearchResults songs.
+func rankResults(results []searchResult) []Song {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return len(results[i].song.Title) < len(results[j].song.Title)
+	})
+	if len(results) > maxSearchResults {
+		results = results[:maxSearchResults]
+	}
+
+	songs := make([]Song, len(results))
+	for i, r := range results {
+		songs[i] = r.song
+	}
+	return songs
+}
+
+// maxQuoteRunes is the length a lyric line is cut to when it is quoted in
+// search results, so a song pasted as one long line cannot crowd out the rest.
+const maxQuoteRunes = 120
+
+// quoteLine shortens line to maxQuoteRunes runes, marking the cut with "…".
+func quoteLine(line string) string {
+	runes := []rune(line)
+	if len(runes) <= maxQuoteRunes {
+		return line
+	}
+	return strings.TrimSpace(string(runes[:maxQuoteRunes-1])) + "…"
+}
+
+// sendLyricsResults lists songs whose lyrics contain phrase, quoting the
+// matching line of each, with the songs as buttons. A list that still
+// exceeds a message, with very long titles, is split like any long text.
+func sendLyricsResults(bot *tgbotapi.BotAPI, chatID int64, phrase string, matches []LyricsMatch) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Songs containing “%s”:\n", capQuery(phrase))
+	songs := make([]Song, len(matches))
+	for i, m := range matches {
+		songs[i] = m.Song
+		fmt.Fprintf(&b, "\n%d. %s\n    “%s”\n", i+1, m.Song.Title, quoteLine(m.Line))
+	}
+	sendLongText(bot, chatID, b.String(), "", songListKeyboard(resultsList(songs), songs, 0))
+}
+
+// sendSearchResults offers ranked results as buttons, or reports no match.
+func sendSearchResults(bot *tgbotapi.BotAPI, chatID int64, text string, results []Song) {
+	if len(results) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "Sorry, I couldn't find the lyrics for that song."))
+		return
+	}
+	sendSongList(bot, chatID, text, resultsList(results), results)
+}
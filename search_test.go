@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSendLyricsResultsFitsMessages(t *testing.T) {
+	bot, fake := newFakeBot(t)
+	long := strings.Repeat("ሃሌ ሉያ amen ", 400)
+	var matches []LyricsMatch
+	for i := 0; i < maxLyricsResults; i++ {
+		matches = append(matches, LyricsMatch{Song: Song{ID: fmt.Sprint(i), Title: fmt.Sprintf("Song %d", i)}, Line: long})
+	}
+
+	sendLyricsResults(bot, 42, strings.Repeat("amen ", 100), matches)
+
+	fake.mu.Lock()
+	calls := fake.calls
+	fake.mu.Unlock()
+	if len(calls) == 0 {
+		t.Fatal("no message sent")
+	}
+	for i, call := range calls {
+		if n := utf8.RuneCountInString(call.Params.Get("text")); n > maxMessageRunes {
+			t.Errorf("message %d is %d runes, over Telegram's %d", i, n, maxMessageRunes)
+		}
+	}
+	text := calls[0].Params.Get("text")
+	if !strings.Contains(text, "Song 9") || !strings.Contains(text, "…”") {
+		t.Errorf("results %q do not list every song with its line shortened", text)
+	}
+	if calls[len(calls)-1].Params.Get("reply_markup") == "" {
+		t.Errorf("results sent without the song buttons")
+	}
+}
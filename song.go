@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSongNotFound is returned by a SongStore when no song matches the lookup.
+var ErrSongNotFound = errors.New("song not found")
+
+// Song is a single mezmur as stored in the song collection.
+type Song struct {
+	ID       string `bson:"-" json:"id"`
+	Title    string `bson:"title" json:"title"`
+	Lyrics   string `bson:"lyrics" json:"lyrics"`
+	Image    string `bson:"image" json:"image"`
+	Category string `bson:"category" json:"category"`
+	Composer string `bson:"composer,omitempty" json:"composer,omitempty"`
+	// Key is the musical key the choir sings the song in, such as "G".
+	Key string `bson:"key,omitempty" json:"key,omitempty"`
+
+	// Attachments are further pages of the song, such as scanned lyrics or
+	// sheet music, in the order they are sent.
+	Attachments []Attachment `bson:"attachments,omitempty" json:"attachments,omitempty"`
+
+	// ImageFileID is the Telegram file_id of Image once it has been sent,
+	// so later sends do not make Telegram fetch the URL again.
+	ImageFileID string `bson:"image_file_id,omitempty" json:"image_file_id,omitempty"`
+
+	// TitleKey is normalizeTitle(Title). Stores keep it up to date on
+	// Insert and Update and use it for title lookups.
+	TitleKey string `bson:"title_key" json:"title_key"`
+	// Structure is parseLyrics(Lyrics), likewise kept up to date by stores.
+	Structure *Structure `bson:"structure,omitempty" json:"structure,omitempty"`
+}
+
+// prepare fills in the fields derived from the title and lyrics. Stores
+// call it before writing a song.
+func (s *Song) prepare() {
+	s.TitleKey = normalizeTitle(s.Title)
+	st := parseLyrics(s.Lyrics)
+	s.Structure = &st
+}
+
+// SongStore is the persistence layer used by the bot handlers.
+type SongStore interface {
+	// Get returns the song with the given ID.
+	Get(ctx context.Context, id string) (*Song, error)
+	// All returns every song.
+	All(ctx context.Context) ([]Song, error)
+	// FindByTitle returns the song whose normalized title matches.
+	FindByTitle(ctx context.Context, title string) (*Song, error)
+	// FindByPrefix returns songs whose normalized title starts with the
+	// normalized prefix.
+	FindByPrefix(ctx context.Context, prefix string) ([]Song, error)
+	// FindByPrefixes returns songs whose title starts with any of prefixes,
+	// ignoring case.
+	FindByPrefixes(ctx context.Context, prefixes []string) ([]Song, error)
+	// ListByCategory returns every song in the given category.
+	ListByCategory(ctx context.Context, category string) ([]Song, error)
+	// Random returns one song picked at random.
+	Random(ctx context.Context) (*Song, error)
+	// Insert stores a new song and sets its ID.
+	Insert(ctx context.Context, song *Song) error
+	// Update replaces the stored song that has the same ID.
+	Update(ctx context.Context, song *Song) error
+	// SetImageFileID records the Telegram file_id of a song's image,
+	// leaving the rest of the song as it is.
+	SetImageFileID(ctx context.Context, id, fileID string) error
+	// SetAttachmentFileID records the Telegram file_id of the song's page
+	// whose Ref is ref, leaving the rest of the song as it is.
+	SetAttachmentFileID(ctx context.Context, id, ref, fileID string) error
+	// Delete removes the song with the given ID.
+	Delete(ctx context.Context, id string) error
+}
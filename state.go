@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// UserState is where a user is in a multi-step conversation such as adding
+// or editing a song.
+type UserState struct {
+	// Wizard names the Wizard the user is in, Step is the index of the
+	// question being asked, and Answers holds what they have entered so far.
+	// Reviewing is set once they have reached the review screen, so editing
+	// a field returns there, and cleared when they go Back from it to walk
+	// through the steps again. Collected counts the messages a multi-message
+	// step has taken so far.
+	Wizard    string            `bson:"wizard,omitempty" json:"wizard,omitempty"`
+	Step      int               `bson:"step,omitempty" json:"step,omitempty"`
+	Answers   map[string]string `bson:"answers,omitempty" json:"answers,omitempty"`
+	Reviewing bool              `bson:"reviewing,omitempty" json:"reviewing,omitempty"`
+	Collected int               `bson:"collected,omitempty" json:"collected,omitempty"`
+
+	// PromptID is the message the bot last asked the user with. In groups
+	// only replies to it count as answers.
+	PromptID int `bson:"prompt_id,omitempty" json:"prompt_id,omitempty"`
+
+	// Stage and Title track flows outside the wizard engine, such as
+	// collecting a song's pages.
+	Stage string `bson:"stage,omitempty" json:"stage,omitempty"`
+	Title string `bson:"title,omitempty" json:"title,omitempty"`
+
+	// UpdatedAt is set by the StateStore on every Put. States left idle for
+	// longer than the store's TTL are dropped.
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// defaultStateTTL is how long an unfinished conversation is kept when
+// STATE_TTL is not set.
+const defaultStateTTL = 24 * time.Hour
+
+// expired reports whether state has been idle for longer than ttl. A ttl of
+// zero keeps states forever.
+func (state UserState) expired(ttl time.Duration) bool {
+	return ttl > 0 && time.Since(state.UpdatedAt) > ttl
+}
+
+// StateKey identifies a conversation: one user in one chat. An admin adding
+// a song in a private chat is not in the middle of anything in the choir
+// group, and the other way round.
+type StateKey struct {
+	ChatID int64 `bson:"chat_id" json:"chat_id"`
+	UserID int   `bson:"user_id" json:"user_id"`
+}
+
+func (k StateKey) String() string {
+	return fmt.Sprintf("%d:%d", k.ChatID, k.UserID)
+}
+
+// stateKey returns the conversation message belongs to.
+func stateKey(message *tgbotapi.Message) StateKey {
+	return StateKey{ChatID: message.Chat.ID, UserID: message.From.ID}
+}
+
+// StateStore keeps each conversation's state, so a wizard can carry on where
+// it left off after the bot restarts.
+type StateStore interface {
+	// Get returns the conversation's state; ok is false when there is none
+	// or it has expired.
+	Get(ctx context.Context, key StateKey) (state UserState, ok bool, err error)
+	// Put saves the conversation's state and marks it as updated now.
+	Put(ctx context.Context, key StateKey, state UserState) error
+	// Delete forgets the conversation's state.
+	Delete(ctx context.Context, key StateKey) error
+}
+
+// userStates is the configured StateStore, set up in main.
+var userStates StateStore = NewMemoryStateStore(defaultStateTTL)
+
+// getState returns the conversation's state, logging store errors.
+func getState(key StateKey) (UserState, bool) {
+	state, ok, err := userStates.Get(context.TODO(), key)
+	if err != nil {
+		log.Printf("Failed to load state %s: %v", key, err)
+		return UserState{}, false
+	}
+	return state, ok
+}
+
+// setState saves the conversation's state, logging store errors.
+func setState(key StateKey, state UserState) {
+	if err := userStates.Put(context.TODO(), key, state); err != nil {
+		log.Printf("Failed to save state %s: %v", key, err)
+	}
+}
+
+// clearState ends the conversation, logging store errors.
+func clearState(key StateKey) {
+	if err := userStates.Delete(context.TODO(), key); err != nil {
+		log.Printf("Failed to clear state %s: %v", key, err)
+	}
+}
+
+// MemoryStateStore is a StateStore that keeps states in memory. It is used
+// with the memory song store and as the base of FileStateStore.
+type MemoryStateStore struct {
+	mu     sync.Mutex
+	states map[StateKey]UserState
+	ttl    time.Duration
+
+	// persist, when set, is called with every state after each change
+	// while the lock is still held.
+	persist func(states map[StateKey]UserState) error
+}
+
+// NewMemoryStateStore returns an empty in-memory StateStore whose states
+// expire after ttl of inactivity.
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	return &MemoryStateStore{states: make(map[StateKey]UserState), ttl: ttl}
+}
+
+func (s *MemoryStateStore) Get(ctx context.Context, key StateKey) (UserState, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok || state.expired(s.ttl) {
+		return UserState{}, false, nil
+	}
+	return state, true, nil
+}
+
+func (s *MemoryStateStore) Put(ctx context.Context, key StateKey, state UserState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.UpdatedAt = time.Now()
+	s.states[key] = state
+	return s.save()
+}
+
+func (s *MemoryStateStore) Delete(ctx context.Context, key StateKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return s.save()
+}
+
+// save drops expired states and persists the rest.
+func (s *MemoryStateStore) save() error {
+	for key, state := range s.states {
+		if state.expired(s.ttl) {
+			delete(s.states, key)
+		}
+	}
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist(s.states)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// FileStateStore is a StateStore kept in memory and saved to a JSON file
+// after every change, for running without a database.
+type FileStateStore struct {
+	*MemoryStateStore
+	path string
+}
+
+// fileState is one entry of the states file.
+type fileState struct {
+	StateKey
+	State UserState `json:"state"`
+}
+
+// NewFileStateStore loads states from path, starting empty if the file does
+// not exist yet. States that expired while the bot was down are dropped.
+func NewFileStateStore(path string, ttl time.Duration) (*FileStateStore, error) {
+	s := &FileStateStore{MemoryStateStore: NewMemoryStateStore(ttl), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err := s.load(bytes.TrimSpace(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for key, state := range s.states {
+		if state.expired(ttl) {
+			delete(s.states, key)
+		}
+	}
+
+	s.persist = s.write
+	return s, nil
+}
+
+func (s *FileStateStore) load(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []fileState
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		s.states[entry.StateKey] = entry.State
+	}
+	return nil
+}
+
+func (s *FileStateStore) write(states map[StateKey]UserState) error {
+	entries := make([]fileState, 0, len(states))
+	for key, state := range states {
+		entries = append(entries, fileState{StateKey: key, State: state})
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoState is the on-disk shape of a state document. Its _id is the
+// key's "<chat>:<user>" string; the key's fields are kept for inspection.
+type mongoState struct {
+	ID        string `bson:"_id"`
+	StateKey  `bson:",inline"`
+	UserState `bson:",inline"`
+}
+
+// MongoStateStore is a StateStore backed by a MongoDB collection.
+type MongoStateStore struct {
+	collection *mongo.Collection
+	ttl        time.Duration
+}
+
+// NewMongoStateStore returns a StateStore that reads and writes collection,
+// treating states idle for longer than ttl as gone.
+func NewMongoStateStore(collection *mongo.Collection, ttl time.Duration) *MongoStateStore {
+	return &MongoStateStore{collection: collection, ttl: ttl}
+}
+
+// EnsureExpiryIndex creates the TTL index with which MongoDB deletes idle
+// states. Get checks expiry itself, as MongoDB only purges once a minute.
+func (s *MongoStateStore) EnsureExpiryIndex(ctx context.Context) error {
+	if s.ttl <= 0 {
+		return nil
+	}
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(s.ttl.Seconds())),
+	})
+	return err
+}
+
+func (s *MongoStateStore) Get(ctx context.Context, key StateKey) (UserState, bool, error) {
+	var doc mongoState
+	err := s.collection.FindOne(ctx, bson.M{"_id": key.String()}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return UserState{}, false, nil
+	}
+	if err != nil {
+		return UserState{}, false, err
+	}
+	if doc.expired(s.ttl) {
+		return UserState{}, false, nil
+	}
+	return doc.UserState, true, nil
+}
+
+func (s *MongoStateStore) Put(ctx context.Context, key StateKey, state UserState) error {
+	state.UpdatedAt = time.Now()
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": key.String()},
+		mongoState{ID: key.String(), StateKey: key, UserState: state}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStateStore) Delete(ctx context.Context, key StateKey) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": key.String()})
+	return err
+}
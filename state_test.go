@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestStateKey(t *testing.T) {
+	private := textMessage(42, 42, "hi")
+	if got, want := stateKey(private), (StateKey{ChatID: 42, UserID: 42}); got != want {
+		t.Errorf("stateKey(private) = %v, want %v", got, want)
+	}
+	group := textMessage(-100, 42, "hi")
+	if got, want := stateKey(group), (StateKey{ChatID: -100, UserID: 42}); got != want {
+		t.Errorf("stateKey(group) = %v, want %v", got, want)
+	}
+	if got := (StateKey{ChatID: -100, UserID: 42}).String(); got != "-100:42" {
+		t.Errorf("StateKey.String() = %q, want %q", got, "-100:42")
+	}
+}
+
+func TestContinuesConversation(t *testing.T) {
+	state := UserState{Wizard: addSongWizard.Name, Step: 1, PromptID: 7}
+	reply := func(m *tgbotapi.Message, to int) *tgbotapi.Message {
+		m.ReplyToMessage = &tgbotapi.Message{MessageID: to, From: &tgbotapi.User{ID: fakeBotID}}
+		return m
+	}
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		state   UserState
+		want    bool
+	}{
+		{"private chat", textMessage(42, 42, "Hymns"), state, true},
+		{"reply to the prompt", reply(textMessage(-100, 42, "Hymns"), 7), state, true},
+		{"reply to another bot message", reply(textMessage(-100, 42, "Hymns"), 6), state, false},
+		{"reply before any prompt", reply(textMessage(-100, 42, "Hymns"), 7), UserState{Wizard: addSongWizard.Name}, false},
+		{"button press", textMessage(-100, 42, wizardBack), state, true},
+		{"group chatter", textMessage(-100, 42, "Hymns"), state, false},
+	}
+	for _, tt := range tests {
+		if got := continuesConversation(tt.message, tt.state); got != tt.want {
+			t.Errorf("%s: continuesConversation = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileStateStoreReload(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "states.json")
+	saved := `[
+  {"chat_id": 42, "user_id": 42, "state": {"stage": "awaiting_pages", "title": "Amazing Grace", "updated_at": "` +
+		time.Now().UTC().Format(time.RFC3339) + `"}},
+  {"chat_id": 43, "user_id": 43, "state": {"stage": "awaiting_pages", "updated_at": "2000-01-01T00:00:00Z"}}
+]`
+	if err := os.WriteFile(path, []byte(saved), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewFileStateStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := StateKey{ChatID: 42, UserID: 42}
+	state, ok, err := store.Get(ctx, key)
+	if err != nil || !ok || state.Title != "Amazing Grace" {
+		t.Fatalf("Get(%v) = %+v, %v, %v; want the saved state", key, state, ok, err)
+	}
+	if _, ok, _ := store.Get(ctx, StateKey{ChatID: 43, UserID: 43}); ok {
+		t.Errorf("expired state was loaded")
+	}
+
+	group := StateKey{ChatID: -100, UserID: 42}
+	if err := store.Put(ctx, group, UserState{Wizard: addSongWizard.Name, PromptID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := NewFileStateStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, ok, _ := reloaded.Get(ctx, key); !ok || state.Title != "Amazing Grace" {
+		t.Errorf("reloaded private state = %+v, %v", state, ok)
+	}
+	if state, ok, _ := reloaded.Get(ctx, group); !ok || state.PromptID != 7 {
+		t.Errorf("reloaded group state = %+v, %v", state, ok)
+	}
+}
+
+func TestWizardRecordsPrompt(t *testing.T) {
+	const group = -100
+	h := newWizardHarness(t, group)
+	startWizard(h.bot, textMessage(group, adminID, "➕ Add Song"), addSongWizard)
+	first, _ := h.state(group)
+	if first.PromptID == 0 || first.PromptID != h.fake.lastMessageID() {
+		t.Fatalf("PromptID = %d, want the question just sent (%d)", first.PromptID, h.fake.lastMessageID())
+	}
+
+	h.run("Amazing Grace")
+	second, _ := h.state(group)
+	if second.PromptID == first.PromptID || second.PromptID != h.fake.lastMessageID() {
+		t.Errorf("PromptID = %d after answering, want the next question (%d)", second.PromptID, h.fake.lastMessageID())
+	}
+	stale := textMessage(group, adminID, "Sunday service")
+	stale.ReplyToMessage = &tgbotapi.Message{MessageID: first.PromptID}
+	if continuesConversation(stale, second) {
+		t.Errorf("reply to an earlier question taken as an answer")
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileSongStore is a SongStore kept in memory and saved to a single JSON
+// file after every change, so the bot can run without a database.
+type FileSongStore struct {
+	*MemorySongStore
+	path string
+}
+
+// NewFileSongStore loads songs from path, starting empty if the file does not
+// exist yet.
+func NewFileSongStore(path string) (*FileSongStore, error) {
+	s := &FileSongStore{MemorySongStore: NewMemorySongStore(), path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &s.songs); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	assigned := false
+	for i := range s.songs {
+		if s.songs[i].ID == "" {
+			if s.songs[i].ID, err = newSongID(); err != nil {
+				return nil, err
+			}
+			assigned = true
+		}
+		s.songs[i].prepare()
+	}
+	// Song buttons carry IDs, so new ones must survive a restart.
+	if assigned {
+		if err := s.write(s.songs); err != nil {
+			return nil, fmt.Errorf("failed to save song IDs to %s: %w", path, err)
+		}
+	}
+
+	s.persist = s.write
+	return s, nil
+}
+
+func (s *FileSongStore) write(songs []Song) error {
+	data, err := json.MarshalIndent(songs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never observe a half-written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSongStoreKeepsGeneratedIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "songs.json")
+	if err := os.WriteFile(path, []byte(`[{"title":"Amazing Grace","lyrics":"Amazing grace"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := NewFileSongStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewFileSongStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := first.All(context.Background())
+	b, _ := second.All(context.Background())
+	if len(a) != 1 || len(b) != 1 {
+		t.Fatalf("got %d and %d songs, want 1", len(a), len(b))
+	}
+	if a[0].ID == "" || a[0].ID != b[0].ID {
+		t.Errorf("ID changed across loads: %q then %q", a[0].ID, b[0].ID)
+	}
+}
+
+func TestMemorySongStoreFailedPersist(t *testing.T) {
+	s := NewMemorySongStore()
+	song := &Song{Title: "Amazing Grace", Lyrics: "Amazing grace"}
+	if err := s.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+
+	errWrite := errors.New("disk full")
+	s.persist = func([]Song) error { return errWrite }
+
+	if err := s.Insert(context.Background(), &Song{Title: "Other"}); !errors.Is(err, errWrite) {
+		t.Errorf("Insert: got %v, want %v", err, errWrite)
+	}
+	changed := *song
+	changed.Title = "Renamed"
+	if err := s.Update(context.Background(), &changed); !errors.Is(err, errWrite) {
+		t.Errorf("Update: got %v, want %v", err, errWrite)
+	}
+	if err := s.Delete(context.Background(), song.ID); !errors.Is(err, errWrite) {
+		t.Errorf("Delete: got %v, want %v", err, errWrite)
+	}
+
+	songs, _ := s.All(context.Background())
+	if len(songs) != 1 || songs[0].Title != "Amazing Grace" {
+		t.Errorf("failed writes changed the store: %+v", songs)
+	}
+}
+
+func TestSetImageFileIDKeepsConcurrentEdits(t *testing.T) {
+	s := NewMemorySongStore()
+	song := &Song{Title: "Amazing Grace", Lyrics: "Amazing grace", Image: "https://example.com/a.jpg"}
+	if err := s.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+	viewed, _ := s.Get(context.Background(), song.ID)
+
+	edited := *song
+	edited.Title = "Amazing Grace (Hymn)"
+	if err := s.Update(context.Background(), &edited); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetImageFileID(context.Background(), viewed.ID, "file-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := s.Get(context.Background(), song.ID)
+	if got.Title != edited.Title || got.ImageFileID != "file-1" {
+		t.Errorf("got title %q and file_id %q, want %q and %q", got.Title, got.ImageFileID, edited.Title, "file-1")
+	}
+	if err := s.SetImageFileID(context.Background(), "missing", "file-1"); err != ErrSongNotFound {
+		t.Errorf("unknown song: got %v, want ErrSongNotFound", err)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// MemorySongStore is a SongStore that keeps every song in memory. It is used
+// for local development and as the base of FileSongStore.
+type MemorySongStore struct {
+	mu    sync.RWMutex
+	songs []Song
+
+	// persist, when set, is called with the full song list a mutation
+	// would leave, while the write lock is still held. The mutation only
+	// takes effect if it succeeds, so memory never runs ahead of the file.
+	persist func(songs []Song) error
+}
+
+// NewMemorySongStore returns an empty in-memory SongStore.
+func NewMemorySongStore() *MemorySongStore {
+	return &MemorySongStore{}
+}
+
+func (s *MemorySongStore) Get(ctx context.Context, id string) (*Song, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if i := s.indexOf(id); i >= 0 {
+		song := s.songs[i]
+		return &song, nil
+	}
+	return nil, ErrSongNotFound
+}
+
+func (s *MemorySongStore) All(ctx context.Context) ([]Song, error) {
+	return s.filter(func(Song) bool { return true }), nil
+}
+
+func (s *MemorySongStore) FindByTitle(ctx context.Context, title string) (*Song, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := normalizeTitle(title)
+	for _, song := range s.songs {
+		if song.TitleKey == key {
+			return &song, nil
+		}
+	}
+	return nil, ErrSongNotFound
+}
+
+func (s *MemorySongStore) FindByPrefix(ctx context.Context, prefix string) ([]Song, error) {
+	prefix = normalizeTitle(prefix)
+	return s.filter(func(song Song) bool {
+		return strings.HasPrefix(song.TitleKey, prefix)
+	}), nil
+}
+
+func (s *MemorySongStore) FindByPrefixes(ctx context.Context, prefixes []string) ([]Song, error) {
+	lower := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		lower[i] = strings.ToLower(prefix)
+	}
+	return s.filter(func(song Song) bool {
+		title := strings.ToLower(song.Title)
+		for _, prefix := range lower {
+			if strings.HasPrefix(title, prefix) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (s *MemorySongStore) ListByCategory(ctx context.Context, category string) ([]Song, error) {
+	return s.filter(func(song Song) bool {
+		return song.Category == category
+	}), nil
+}
+
+func (s *MemorySongStore) Random(ctx context.Context) (*Song, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.songs) == 0 {
+		return nil, ErrSongNotFound
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(s.songs))))
+	if err != nil {
+		return nil, err
+	}
+	song := s.songs[n.Int64()]
+	return &song, nil
+}
+
+func (s *MemorySongStore) Insert(ctx context.Context, song *Song) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, err := newSongID()
+	if err != nil {
+		return err
+	}
+	song.ID = id
+	song.prepare()
+	return s.commit(append(s.songs[:len(s.songs):len(s.songs)], *song))
+}
+
+func (s *MemorySongStore) Update(ctx context.Context, song *Song) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(song.ID)
+	if i < 0 {
+		return ErrSongNotFound
+	}
+	song.prepare()
+	songs := append([]Song(nil), s.songs...)
+	songs[i] = *song
+	return s.commit(songs)
+}
+
+func (s *MemorySongStore) SetImageFileID(ctx context.Context, id, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrSongNotFound
+	}
+	songs := append([]Song(nil), s.songs...)
+	songs[i].ImageFileID = fileID
+	return s.commit(songs)
+}
+
+func (s *MemorySongStore) SetAttachmentFileID(ctx context.Context, id, ref, fileID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrSongNotFound
+	}
+	songs := append([]Song(nil), s.songs...)
+	attachments := append([]Attachment(nil), songs[i].Attachments...)
+	for j := range attachments {
+		if attachments[j].Ref == ref {
+			attachments[j].FileID = fileID
+		}
+	}
+	songs[i].Attachments = attachments
+	return s.commit(songs)
+}
+
+func (s *MemorySongStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.indexOf(id)
+	if i < 0 {
+		return ErrSongNotFound
+	}
+	return s.commit(append(s.songs[:i:i], s.songs[i+1:]...))
+}
+
+func (s *MemorySongStore) indexOf(id string) int {
+	for i, song := range s.songs {
+		if song.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *MemorySongStore) filter(match func(Song) bool) []Song {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var songs []Song
+	for _, song := range s.songs {
+		if match(song) {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
+
+// commit persists songs and only then makes them the store's contents. The
+// caller must hold the write lock and pass a slice that does not share its
+// backing array with s.songs.
+func (s *MemorySongStore) commit(songs []Song) error {
+	if s.persist != nil {
+		if err := s.persist(songs); err != nil {
+			return err
+		}
+	}
+	s.songs = songs
+	return nil
+}
+
+// newSongID returns a random 24-character hex ID, the same shape as a
+// MongoDB ObjectID so IDs look alike whichever backend is in use.
+func newSongID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
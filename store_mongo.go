@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// mongoSong is the on-disk shape of a song document.
+type mongoSong struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty"`
+	Song `bson:",inline"`
+}
+
+func (d mongoSong) song() Song {
+	s := d.Song
+	s.ID = d.ID.Hex()
+	return s
+}
+
+// MongoSongStore is a SongStore backed by a MongoDB collection.
+type MongoSongStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSongStore returns a SongStore that reads and writes collection.
+func NewMongoSongStore(collection *mongo.Collection) *MongoSongStore {
+	return &MongoSongStore{collection: collection}
+}
+
+func (s *MongoSongStore) Get(ctx context.Context, id string) (*Song, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSongNotFound
+	}
+	return s.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (s *MongoSongStore) All(ctx context.Context) ([]Song, error) {
+	return s.find(ctx, bson.M{})
+}
+
+func (s *MongoSongStore) FindByTitle(ctx context.Context, title string) (*Song, error) {
+	return s.findOne(ctx, bson.M{"title_key": normalizeTitle(title)})
+}
+
+func (s *MongoSongStore) FindByPrefix(ctx context.Context, prefix string) ([]Song, error) {
+	return s.find(ctx, bson.M{"title_key": prefixRegex(normalizeTitle(prefix), false)})
+}
+
+func (s *MongoSongStore) FindByPrefixes(ctx context.Context, prefixes []string) ([]Song, error) {
+	patterns := make([]primitive.Regex, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		patterns = append(patterns, prefixRegex(prefix, true))
+	}
+	return s.find(ctx, bson.M{"title": bson.M{"$in": patterns}})
+}
+
+func (s *MongoSongStore) ListByCategory(ctx context.Context, category string) ([]Song, error) {
+	return s.find(ctx, bson.M{"category": category})
+}
+
+func (s *MongoSongStore) Random(ctx context.Context) (*Song, error) {
+	pipeline := []bson.M{{"$sample": bson.M{"size": 1}}}
+	cursor, err := s.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrSongNotFound
+	}
+	var doc mongoSong
+	if err := cursor.Decode(&doc); err != nil {
+		return nil, err
+	}
+	song := doc.song()
+	return &song, nil
+}
+
+func (s *MongoSongStore) Insert(ctx context.Context, song *Song) error {
+	song.prepare()
+	res, err := s.collection.InsertOne(ctx, mongoSong{Song: *song})
+	if err != nil {
+		return err
+	}
+	if oid, ok := res.InsertedID.(primitive.ObjectID); ok {
+		song.ID = oid.Hex()
+	}
+	return nil
+}
+
+func (s *MongoSongStore) Update(ctx context.Context, song *Song) error {
+	oid, err := primitive.ObjectIDFromHex(song.ID)
+	if err != nil {
+		return ErrSongNotFound
+	}
+	song.prepare()
+	res, err := s.collection.ReplaceOne(ctx, bson.M{"_id": oid}, mongoSong{Song: *song})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSongNotFound
+	}
+	return nil
+}
+
+func (s *MongoSongStore) SetImageFileID(ctx context.Context, id, fileID string) error {
+	return s.set(ctx, id, bson.M{"image_file_id": fileID})
+}
+
+// SetAttachmentFileID does nothing if the page has been removed meanwhile.
+func (s *MongoSongStore) SetAttachmentFileID(ctx context.Context, id, ref, fileID string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSongNotFound
+	}
+	_, err = s.collection.UpdateOne(ctx,
+		bson.M{"_id": oid, "attachments.ref": ref},
+		bson.M{"$set": bson.M{"attachments.$.file_id": fileID}})
+	return err
+}
+
+func (s *MongoSongStore) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSongNotFound
+	}
+	res, err := s.collection.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrSongNotFound
+	}
+	return nil
+}
+
+// EnsureDerivedFields fills in title_key and structure on every document
+// where they are missing or no longer match the title and lyrics, for songs
+// added before those fields existed or edited directly in the database.
+func (s *MongoSongStore) EnsureDerivedFields(ctx context.Context) error {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc mongoSong
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("Skipping malformed song %v: %v", cursor.Current.Lookup("_id"), err)
+			continue
+		}
+		stale := doc.Song
+		doc.prepare()
+		if stale.TitleKey == doc.TitleKey && reflect.DeepEqual(stale.Structure, doc.Structure) {
+			continue
+		}
+		_, err := s.collection.UpdateOne(ctx, bson.M{"_id": doc.ID}, bson.M{"$set": bson.M{
+			"title_key": doc.TitleKey,
+			"structure": doc.Structure,
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// set updates only the given fields of the song with the given ID, so it
+// cannot undo an edit made since the song was read.
+func (s *MongoSongStore) set(ctx context.Context, id string, fields bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSongNotFound
+	}
+	res, err := s.collection.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSongNotFound
+	}
+	return nil
+}
+
+func (s *MongoSongStore) findOne(ctx context.Context, filter bson.M) (*Song, error) {
+	var doc mongoSong
+	err := s.collection.FindOne(ctx, filter).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrSongNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	song := doc.song()
+	return &song, nil
+}
+
+// find decodes every matching document, skipping (and logging) malformed
+// ones so a single bad record cannot hide the rest of the list.
+func (s *MongoSongStore) find(ctx context.Context, filter bson.M) ([]Song, error) {
+	cursor, err := s.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var songs []Song
+	for cursor.Next(ctx) {
+		var doc mongoSong
+		if err := cursor.Decode(&doc); err != nil {
+			log.Printf("Skipping malformed song %v: %v", cursor.Current.Lookup("_id"), err)
+			continue
+		}
+		songs = append(songs, doc.song())
+	}
+	return songs, cursor.Err()
+}
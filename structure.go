@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SectionType is the role of a block of lyrics within a song.
+type SectionType string
+
+const (
+	SectionVerse  SectionType = "verse"
+	SectionChorus SectionType = "chorus"
+	SectionBridge SectionType = "bridge"
+)
+
+// Section is one stanza of a song.
+type Section struct {
+	Type  SectionType `bson:"type" json:"type"`
+	Label string      `bson:"label" json:"label"`
+	Lines []string    `bson:"lines" json:"lines"`
+}
+
+// Structure is a song's lyrics split into sections, with Order listing the
+// section indexes in the order they are sung, repeats included.
+type Structure struct {
+	Sections []Section `bson:"sections" json:"sections"`
+	Order    []int     `bson:"order" json:"order"`
+}
+
+// sectionHeaders recognise stanza headers such as "Chorus:", "Verse 2",
+// "አዝማች:" or "Bridge x2", in English and Amharic. Groups are the name, an
+// optional number, an optional colon and any lyric text after the colon.
+var sectionHeaders = []struct {
+	typ     SectionType
+	pattern *regexp.Regexp
+}{
+	{SectionChorus, regexp.MustCompile(`(?i)^(chorus|refrain|አዝማች|አዝ)(?:\.?\s*(\d+))?\s*(:)?\s*(.*)$`)},
+	{SectionBridge, regexp.MustCompile(`(?i)^(bridge|ድልድይ)(?:\.?\s*(\d+))?\s*(:)?\s*(.*)$`)},
+	{SectionVerse, regexp.MustCompile(`(?i)^(verse|ቁጥር)(?:\.?\s*(\d+))?\s*(:)?\s*(.*)$`)},
+}
+
+// stanzaBreak separates stanzas: a blank or whitespace-only line.
+var stanzaBreak = regexp.MustCompile(`\n\s*\n`)
+
+// repeatMarker matches "x2", "×3" or "(x2)" at the end of a line.
+var repeatMarker = regexp.MustCompile(`(?i)\s*\(?\s*[x×]\s*(\d+)\s*\)?\s*$`)
+
+// parseRepeat strips a trailing repeat marker from s and returns the count,
+// which is 1 when there is no marker.
+func parseRepeat(s string) (string, int) {
+	m := repeatMarker.FindStringSubmatchIndex(s)
+	if m == nil {
+		return s, 1
+	}
+	n, err := strconv.Atoi(s[m[2]:m[3]])
+	if err != nil || n < 1 {
+		return s, 1
+	}
+	return s[:m[0]], n
+}
+
+// parseHeader recognises a section header line. name and number are as
+// written; rest is any lyric text after a colon, as in "Chorus: Hallelujah".
+func parseHeader(line string) (typ SectionType, name, number, rest string, repeat int, ok bool) {
+	line, repeat = parseRepeat(strings.TrimSpace(line))
+	for _, h := range sectionHeaders {
+		m := h.pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		// "Chorusline" or "Verses of praise" are lyrics, not headers.
+		if m[4] != "" && m[3] == "" {
+			return "", "", "", "", 0, false
+		}
+		return h.typ, m[1], m[2], m[4], repeat, true
+	}
+	return "", "", "", "", 0, false
+}
+
+// parseLyrics converts lyrics written in the choir's plain-text convention
+// into a Structure. Stanzas are separated by blank lines; a stanza may start
+// with a header ("Chorus:", "Verse 2:", "Bridge:"); a stanza that is only a
+// header repeats the section of that name; a trailing "x2" on the header or
+// on its own line repeats the stanza. When a song has a single chorus that
+// is never referenced again, it is sung after every verse that follows it.
+func parseLyrics(lyrics string) Structure {
+	var st Structure
+	byLabel := make(map[string]int)
+	explicitChorus := false
+	verses := 0
+
+	lyrics = strings.ReplaceAll(lyrics, "\r\n", "\n")
+	for _, stanza := range stanzaBreak.Split(strings.TrimSpace(lyrics), -1) {
+		lines := strings.Split(strings.TrimSpace(stanza), "\n")
+		if len(lines) == 0 || lines[0] == "" {
+			continue
+		}
+
+		sec := Section{Type: SectionVerse}
+		repeat := 1
+		name, number := "Verse", ""
+		if typ, hName, hNumber, rest, n, ok := parseHeader(lines[0]); ok {
+			sec.Type, name, number, repeat = typ, hName, hNumber, n
+			sec.Label = strings.TrimSpace(name + " " + number)
+			lines = lines[1:]
+			if rest != "" {
+				lines = append([]string{rest}, lines...)
+			}
+		}
+
+		// A repeat marker on its own last line applies to the whole stanza.
+		if len(lines) > 0 {
+			if stripped, n := parseRepeat(lines[len(lines)-1]); n > 1 && strings.TrimSpace(stripped) == "" {
+				repeat *= n
+				lines = lines[:len(lines)-1]
+			}
+		}
+		for i := range lines {
+			lines[i] = strings.TrimSpace(lines[i])
+		}
+
+		// A bare header refers back to a section defined earlier.
+		if len(lines) == 0 {
+			key := strings.ToLower(sec.Label)
+			if i, ok := byLabel[key]; ok {
+				if st.Sections[i].Type == SectionChorus {
+					explicitChorus = true
+				}
+				for r := 0; r < repeat; r++ {
+					st.Order = append(st.Order, i)
+				}
+			}
+			continue
+		}
+
+		if sec.Type == SectionVerse {
+			verses++
+			if number == "" {
+				sec.Label = fmt.Sprintf("%s %d", name, verses)
+			}
+		}
+		sec.Lines = lines
+
+		idx := len(st.Sections)
+		st.Sections = append(st.Sections, sec)
+		byLabel[strings.ToLower(sec.Label)] = idx
+		for r := 0; r < repeat; r++ {
+			st.Order = append(st.Order, idx)
+		}
+	}
+
+	if !explicitChorus {
+		st.Order = insertImpliedChorus(st)
+	}
+	return st
+}
+
+// insertImpliedChorus returns st.Order with the song's only chorus sung after
+// each verse that follows its first appearance.
+func insertImpliedChorus(st Structure) []int {
+	chorus := -1
+	for i, sec := range st.Sections {
+		if sec.Type == SectionChorus {
+			if chorus >= 0 {
+				return st.Order
+			}
+			chorus = i
+		}
+	}
+	if chorus < 0 {
+		return st.Order
+	}
+
+	var order []int
+	seen := false
+	for i, idx := range st.Order {
+		order = append(order, idx)
+		if idx == chorus {
+			seen = true
+			continue
+		}
+		next := -1
+		if i+1 < len(st.Order) {
+			next = st.Order[i+1]
+		}
+		if seen && st.Sections[idx].Type == SectionVerse && next != chorus {
+			order = append(order, chorus)
+		}
+	}
+	return order
+}
+
+// Structured returns the song's sections, parsing the plain-text lyrics for
+// songs stored before sections were recorded.
+func (s *Song) Structured() Structure {
+	if s.Structure != nil && len(s.Structure.Sections) > 0 {
+		return *s.Structure
+	}
+	return parseLyrics(s.Lyrics)
+}
+
+// renderSectionsHTML renders st as Telegram HTML. Each section is printed in
+// full the first time it is sung; later repeats are shown only as a
+// reference such as "(Chorus)", and back-to-back repeats as "×2". Songs made
+// only of verses are printed without labels, as they were written.
+func renderSectionsHTML(st Structure) string {
+	labelled := false
+	for _, sec := range st.Sections {
+		if sec.Type != SectionVerse {
+			labelled = true
+		}
+	}
+
+	var b strings.Builder
+	printed := make(map[int]bool)
+	for i := 0; i < len(st.Order); {
+		idx := st.Order[i]
+		n := 1
+		for i+n < len(st.Order) && st.Order[i+n] == idx {
+			n++
+		}
+		i += n
+		if idx < 0 || idx >= len(st.Sections) {
+			continue
+		}
+		sec := st.Sections[idx]
+
+		label := html.EscapeString(sec.Label)
+		if n > 1 {
+			label += fmt.Sprintf(" ×%d", n)
+		}
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		if printed[idx] {
+			fmt.Fprintf(&b, "<i>(%s)</i>", label)
+			continue
+		}
+		printed[idx] = true
+
+		if labelled || n > 1 {
+			fmt.Fprintf(&b, "<i>%s</i>\n", label)
+		}
+		b.WriteString(html.EscapeString(strings.Join(sec.Lines, "\n")))
+	}
+	return b.String()
+}
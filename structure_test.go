@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestParseLyrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		lyrics   string
+		sections []Section
+		order    []int
+	}{
+		{
+			name:   "verses only",
+			lyrics: "a\nb\n\nc\nd",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a", "b"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"c", "d"}},
+			},
+			order: []int{0, 1},
+		},
+		{
+			name:   "headers",
+			lyrics: "Verse 1:\na\n\nChorus:\nb\n\nVerse 2:\nc\n\nChorus\n\nBridge:\nd",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a"}},
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"b"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"c"}},
+				{Type: SectionBridge, Label: "Bridge", Lines: []string{"d"}},
+			},
+			order: []int{0, 1, 2, 1, 3},
+		},
+		{
+			name:   "amharic headers and text after the colon",
+			lyrics: "ቁጥር 1: ሀ\nለ\n\nአዝማች: ሐ",
+			sections: []Section{
+				{Type: SectionVerse, Label: "ቁጥር 1", Lines: []string{"ሀ", "ለ"}},
+				{Type: SectionChorus, Label: "አዝማች", Lines: []string{"ሐ"}},
+			},
+			order: []int{0, 1},
+		},
+		{
+			name:   "repeat marker on the header",
+			lyrics: "Chorus x2\na",
+			sections: []Section{
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"a"}},
+			},
+			order: []int{0, 0},
+		},
+		{
+			name:   "repeat marker on its own line",
+			lyrics: "a\nb\n×3",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a", "b"}},
+			},
+			order: []int{0, 0, 0},
+		},
+		{
+			name:   "bare header repeats the section",
+			lyrics: "Chorus:\na\n\nVerse:\nb\n\nChorus (x2)",
+			sections: []Section{
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"a"}},
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"b"}},
+			},
+			order: []int{0, 1, 0, 0},
+		},
+		{
+			name:   "bare header for an unknown section is dropped",
+			lyrics: "a\n\nBridge",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a"}},
+			},
+			order: []int{0},
+		},
+		{
+			name:   "implied chorus after later verses",
+			lyrics: "a\n\nChorus:\nb\n\nc\n\nd",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a"}},
+				{Type: SectionChorus, Label: "Chorus", Lines: []string{"b"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"c"}},
+				{Type: SectionVerse, Label: "Verse 3", Lines: []string{"d"}},
+			},
+			order: []int{0, 1, 2, 1, 3, 1},
+		},
+		{
+			name:   "two choruses are not implied",
+			lyrics: "Chorus 1:\na\n\nb\n\nChorus 2:\nc\n\nd",
+			sections: []Section{
+				{Type: SectionChorus, Label: "Chorus 1", Lines: []string{"a"}},
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"b"}},
+				{Type: SectionChorus, Label: "Chorus 2", Lines: []string{"c"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"d"}},
+			},
+			order: []int{0, 1, 2, 3},
+		},
+		{
+			name:   "words starting with a header name are lyrics",
+			lyrics: "Chorusline of angels\nVerses of praise",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"Chorusline of angels", "Verses of praise"}},
+			},
+			order: []int{0},
+		},
+		{
+			name:   "windows line ends and blank lines with spaces",
+			lyrics: "a\r\n \r\nb\r\n",
+			sections: []Section{
+				{Type: SectionVerse, Label: "Verse 1", Lines: []string{"a"}},
+				{Type: SectionVerse, Label: "Verse 2", Lines: []string{"b"}},
+			},
+			order: []int{0, 1},
+		},
+	}
+	for _, tt := range tests {
+		st := parseLyrics(tt.lyrics)
+		if !reflect.DeepEqual(st.Sections, tt.sections) {
+			t.Errorf("%s: sections = %+v, want %+v", tt.name, st.Sections, tt.sections)
+		}
+		if !reflect.DeepEqual(st.Order, tt.order) {
+			t.Errorf("%s: order = %v, want %v", tt.name, st.Order, tt.order)
+		}
+	}
+}
+
+func TestParseLyricsEmpty(t *testing.T) {
+	if st := parseLyrics(" \n\n "); len(st.Sections) != 0 || len(st.Order) != 0 {
+		t.Errorf("parseLyrics(blank) = %+v, want nothing", st)
+	}
+}
+
+// EnsureDerivedFields compares the stored structure with a fresh parse, so
+// a structure must read back from BSON exactly as it was parsed.
+func TestStructureBSONRoundTrip(t *testing.T) {
+	for _, lyrics := range []string{"", "a\n\nChorus:\nb\n\nc", "Chorus x2:\nሀ"} {
+		song := Song{Title: "t", Lyrics: lyrics}
+		song.prepare()
+		data, err := bson.Marshal(song)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Song
+		if err := bson.Unmarshal(data, &back); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back.Structure, song.Structure) {
+			t.Errorf("%q: structure read back as %+v, want %+v", lyrics, back.Structure, song.Structure)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+)
+
+// fidelConsonants gives the Latin spelling of each consonant family, after
+// homophone folding, in the SERA-like style choir members type on phones.
+// አ carries only a vowel.
+var fidelConsonants = map[rune]string{
+	'ሀ': "h", 'ለ': "l", 'መ': "m", 'ረ': "r", 'ሰ': "s", 'ሸ': "sh",
+	'ቀ': "q", 'ቐ': "q", 'በ': "b", 'ቨ': "v", 'ተ': "t", 'ቸ': "ch",
+	'ነ': "n", 'ኘ': "ny", 'አ': "", 'ከ': "k", 'ኸ': "h", 'ወ': "w",
+	'ዘ': "z", 'ዠ': "zh", 'የ': "y", 'ደ': "d", 'ዸ': "d", 'ጀ': "j",
+	'ገ': "g", 'ጘ': "ng", 'ጠ': "t", 'ጨ': "ch", 'ጰ': "p", 'ጸ': "ts",
+	'ፈ': "f", 'ፐ': "p",
+}
+
+// fidelVowels gives the vowel of each of the seven orders. The sixth order is
+// usually silent; the bare vowel carrier አ reads "a" and "e" instead.
+var fidelVowels = [8]string{"e", "u", "i", "a", "e", "", "o", "wa"}
+
+// transliterate renders a title in Latin letters, so እግዚአብሔር becomes
+// "egziabher". Non-Ethiopic text is normalized and passed through.
+func transliterate(text string) string {
+	var b strings.Builder
+	for _, r := range normalizeTitle(text) {
+		if !isFidel(r) {
+			b.WriteRune(r)
+			continue
+		}
+		base := fidelBase(r)
+		consonant, ok := fidelConsonants[base]
+		if !ok {
+			continue
+		}
+		order := r - base
+		b.WriteString(consonant)
+		switch {
+		case base == 'አ' && order == 0:
+			b.WriteString("a")
+		case base == 'አ' && order == 5:
+			b.WriteString("e")
+		default:
+			b.WriteString(fidelVowels[order])
+		}
+	}
+	return b.String()
+}
+
+// phoneticDigraphs fold Latin spellings that stand for a single sound.
+var phoneticDigraphs = strings.NewReplacer(
+	"sh", "S", "ch", "C", "zh", "Z", "ny", "N", "ts", "s", "ph", "f",
+	"kh", "h", "gn", "N",
+)
+
+// phoneticLetters folds letters that choir members use interchangeably.
+var phoneticLetters = map[rune]rune{
+	'c': 'k', 'q': 'k', 'v': 'b', 'x': 'S',
+}
+
+// phoneticKey returns the consonant skeleton of text, in Ge'ez or Latin
+// script. Vowels, spaces and doubled letters are dropped because Ethiopic
+// spelling does not mark gemination and Latin spellings of the sixth order
+// vary, so "egziabher", "egzi abiher" and እግዚአብሔር share the key "gzbhr".
+// A y inside a word is a glide Latin spellings often omit (ጽዮን, "tsion").
+func phoneticKey(text string) string {
+	latin := phoneticDigraphs.Replace(transliterate(text))
+
+	var b strings.Builder
+	var last rune
+	wordStart := true
+	for _, r := range latin {
+		if folded, ok := phoneticLetters[r]; ok {
+			r = folded
+		}
+		switch {
+		case strings.ContainsRune("aeiou", r):
+			last, wordStart = 0, false
+			continue
+		case (r < 'a' || r > 'z') && !strings.ContainsRune("SCZN", r):
+			last, wordStart = 0, true
+			continue
+		case r == 'y' && !wordStart, r == last:
+			continue
+		}
+		b.WriteRune(r)
+		last, wordStart = r, false
+	}
+	return b.String()
+}
+
+// isLatinText reports whether text contains Latin letters and no Ethiopic.
+func isLatinText(text string) bool {
+	latin := false
+	for _, r := range text {
+		if isFidel(r) {
+			return false
+		}
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			latin = true
+		}
+	}
+	return latin
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"እግዚአብሔር", "egziabher"},
+		{"ጽዮን", "tsyon"},
+		{"ሰላም", "selam"},
+		{"ቃል", "qal"},
+		{"ቸር", "cher"},
+		{"ኢየሱስ", "iyesus"},
+		{"Amazing Grace", "amazing grace"},
+	}
+	for _, tt := range tests {
+		if got := transliterate(tt.in); got != tt.want {
+			t.Errorf("transliterate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPhoneticKey(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"እግዚአብሔር", "gzbhr"},
+		{"egziabher", "gzbhr"},
+		{"egzi abiher", "gzbhr"},
+		{"Egziabher", "gzbhr"},
+		{"ጽዮን", "sn"},
+		{"tsion", "sn"},
+		{"ሰላም", "slm"},
+		{"salam", "slm"},
+		{"ቃል", "kl"},
+		{"kal", "kl"},
+		{"ቸር", "Cr"},
+		{"cher", "Cr"},
+	}
+	for _, tt := range tests {
+		if got := phoneticKey(tt.in); got != tt.want {
+			t.Errorf("phoneticKey(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestIsLatinText(t *testing.T) {
+	for in, want := range map[string]bool{"egziabher": true, "Amazing Grace 2": true, "እግዚአብሔር": false, "ሰላም selam": false} {
+		if got := isLatinText(in); got != want {
+			t.Errorf("isLatinText(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Navigation buttons shown under wizard steps and on the review screen.
+const (
+	wizardBack      = "⬅️ Back"
+	wizardSkip      = "Skip"
+	wizardDone      = "✅ Done"
+	wizardCancel    = "Cancel"
+	wizardSave      = "✅ Save"
+	wizardEditField = "✏️ Edit field"
+	wizardDiscard   = "🗑 Discard"
+)
+
+// Wizard is a multi-step conversation declared as a list of steps. The
+// engine asks each step's question in turn, records the answers in the
+// user's state, and calls Done once the last step has been answered. A
+// wizard with Review first shows the answers with Save, Edit field and
+// Discard buttons, and only calls Done on Save.
+type Wizard struct {
+	// Name identifies the wizard in a saved UserState.
+	Name  string
+	Steps []WizardStep
+	// Review, if set, renders the answers as HTML for the review screen.
+	Review func(answers map[string]string) string
+	// Done receives every answer once the wizard is complete.
+	Done func(bot *tgbotapi.BotAPI, store SongStore, chatID int64, answers map[string]string)
+	// Cancel, if set, cleans up after answers that are discarded, such as
+	// images already uploaded.
+	Cancel func(answers map[string]string)
+}
+
+// WizardStep is one question of a Wizard.
+type WizardStep struct {
+	// Name identifies the step; Accept usually records its answer under it.
+	Name string
+	// Label names the step on the review screen's Edit field keyboard.
+	Label string
+	// Prompt returns the question, given the answers so far.
+	Prompt func(answers map[string]string) string
+	// Buttons are choices offered on the reply keyboard with the prompt.
+	Buttons []string
+	// When, if set, says whether the step is asked given the answers so
+	// far. Steps it rules out are passed over going forward and back.
+	When func(answers map[string]string) bool
+	// Optional steps can be skipped even before they have an answer.
+	// Other steps can only be skipped to keep an answer already given.
+	Optional bool
+	// Multi steps take any number of messages, each passed to Accept, until
+	// the user presses Done. The first message replaces an earlier answer
+	// and Progress, if set, is sent after each one.
+	Multi    bool
+	Progress func(answers map[string]string) string
+	// Accept validates message and records the answer in answers. A
+	// returned error is shown to the user, who is asked again.
+	Accept func(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error
+}
+
+// wizards lists every wizard by name, so a saved state can be resumed.
+var wizards = map[string]*Wizard{
+	addSongWizard.Name:  addSongWizard,
+	editSongWizard.Name: editSongWizard,
+}
+
+// prompt returns a Prompt that always asks text.
+func prompt(text string) func(map[string]string) string {
+	return func(map[string]string) string { return text }
+}
+
+// moveTo sends the user to step, starting its collection afresh.
+func (state *UserState) moveTo(step int) {
+	state.Step = step
+	state.Collected = 0
+}
+
+// reviewStep and pickStep are the pseudo-steps after a wizard's last step:
+// the review screen, and choosing which field to edit from it.
+func (w *Wizard) reviewStep() int { return len(w.Steps) }
+func (w *Wizard) pickStep() int   { return len(w.Steps) + 1 }
+
+// applies reports whether step is asked given answers.
+func (step WizardStep) applies(answers map[string]string) bool {
+	return step.When == nil || step.When(answers)
+}
+
+// nextStep returns the first step after step that applies, or len(w.Steps)
+// when there is none.
+func (w *Wizard) nextStep(step int, answers map[string]string) int {
+	for step++; step < len(w.Steps) && !w.Steps[step].applies(answers); step++ {
+	}
+	return step
+}
+
+// prevStep returns the last step before step that applies, or -1 when there
+// is none.
+func (w *Wizard) prevStep(step int, answers map[string]string) int {
+	for step--; step >= 0 && !w.Steps[step].applies(answers); step-- {
+	}
+	return step
+}
+
+// hasStep reports whether step is one the user can be at in w.
+func (w *Wizard) hasStep(step int) bool {
+	if w.Review != nil {
+		return step >= 0 && step <= w.pickStep()
+	}
+	return step >= 0 && step < len(w.Steps)
+}
+
+// startWizard begins w for the sender of message and asks its first question.
+func startWizard(bot *tgbotapi.BotAPI, message *tgbotapi.Message, w *Wizard) {
+	state := UserState{Wizard: w.Name, Answers: map[string]string{}}
+	state.moveTo(w.nextStep(-1, state.Answers))
+	askStep(bot, message, w, state)
+}
+
+// askStep sends the question of the user's current step, or the review
+// screen or field list when they are past the last step, and saves state
+// with it as the prompt. In groups it replies to message, so the keyboard
+// is shown only to its sender.
+func askStep(bot *tgbotapi.BotAPI, message *tgbotapi.Message, w *Wizard, state UserState) {
+	var text string
+	switch state.Step {
+	case w.reviewStep():
+		// The review can run over several messages; the buttons follow it.
+		if err := sendLongText(bot, message.Chat.ID, w.Review(state.Answers), tgbotapi.ModeHTML, nil); err != nil {
+			setState(stateKey(message), state)
+			return
+		}
+		text = "Save, change a field, or discard?"
+	case w.pickStep():
+		text = "Which field would you like to change?"
+	default:
+		text = w.Steps[state.Step].Prompt(state.Answers)
+	}
+
+	if !message.Chat.IsPrivate() {
+		text += "\n\n(Reply to this message to answer.)"
+	}
+	msg := replyTo(message, text)
+	msg.ReplyMarkup = wizardKeyboard(w, state)
+	sendPrompt(bot, message, state, msg)
+}
+
+// replyTo returns a message with text for message's chat. In groups it is
+// sent as a reply to message, which a selective keyboard needs to know whom
+// to show itself to.
+func replyTo(message *tgbotapi.Message, text string) tgbotapi.MessageConfig {
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if !message.Chat.IsPrivate() {
+		msg.ReplyToMessageID = message.MessageID
+	}
+	return msg
+}
+
+// sendPrompt sends msg, which asks the sender of message for their next
+// answer, and saves state with msg as its prompt: in groups only replies to
+// the latest prompt are taken as answers.
+func sendPrompt(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state UserState, msg tgbotapi.MessageConfig) {
+	if sent, err := bot.Send(msg); err == nil {
+		state.PromptID = sent.MessageID
+	}
+	setState(stateKey(message), state)
+}
+
+// wizardKeyboard returns the reply keyboard offered at the user's step.
+func wizardKeyboard(w *Wizard, state UserState) tgbotapi.ReplyKeyboardMarkup {
+	var keyboard tgbotapi.ReplyKeyboardMarkup
+	switch state.Step {
+	case w.reviewStep():
+		keyboard = tgbotapi.NewReplyKeyboard(
+			tgbotapi.NewKeyboardButtonRow(
+				tgbotapi.NewKeyboardButton(wizardSave),
+				tgbotapi.NewKeyboardButton(wizardEditField),
+			),
+			tgbotapi.NewKeyboardButtonRow(
+				tgbotapi.NewKeyboardButton(wizardBack),
+				tgbotapi.NewKeyboardButton(wizardDiscard),
+			),
+		)
+	case w.pickStep():
+		var labels []string
+		for _, step := range w.Steps {
+			if step.applies(state.Answers) {
+				labels = append(labels, step.Label)
+			}
+		}
+		keyboard = stepKeyboard(WizardStep{Buttons: labels}, true, false)
+	default:
+		step := w.Steps[state.Step]
+		_, answered := state.Answers[step.Name]
+		canGoBack := w.prevStep(state.Step, state.Answers) >= 0 || state.Reviewing
+		keyboard = stepKeyboard(step, canGoBack, step.Optional || answered)
+	}
+	// In groups only the admin running the wizard sees its buttons.
+	keyboard.Selective = true
+	return keyboard
+}
+
+// continuesConversation reports whether message is meant for the sender's
+// conversation in its chat. In private chats every message is. In groups
+// only replies to the conversation's latest prompt and presses of the
+// buttons it offered are, so talking in the group, or replying to the bot's
+// other messages, is never taken as an answer.
+func continuesConversation(message *tgbotapi.Message, state UserState) bool {
+	if message.Chat.IsPrivate() {
+		return true
+	}
+	if reply := message.ReplyToMessage; reply != nil && state.PromptID != 0 && reply.MessageID == state.PromptID {
+		return true
+	}
+	w, ok := wizards[state.Wizard]
+	if !ok || !w.hasStep(state.Step) || message.Text == "" {
+		return false
+	}
+	for _, row := range wizardKeyboard(w, state).Keyboard {
+		for _, button := range row {
+			if button.Text == message.Text {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stepKeyboard lays out the step's buttons two to a row, followed by the
+// navigation row.
+func stepKeyboard(step WizardStep, canGoBack, canSkip bool) tgbotapi.ReplyKeyboardMarkup {
+	var rows [][]tgbotapi.KeyboardButton
+	for i := 0; i < len(step.Buttons); i += 2 {
+		row := []tgbotapi.KeyboardButton{tgbotapi.NewKeyboardButton(step.Buttons[i])}
+		if i+1 < len(step.Buttons) {
+			row = append(row, tgbotapi.NewKeyboardButton(step.Buttons[i+1]))
+		}
+		rows = append(rows, row)
+	}
+
+	var nav []tgbotapi.KeyboardButton
+	if step.Multi {
+		nav = append(nav, tgbotapi.NewKeyboardButton(wizardDone))
+	}
+	if canGoBack {
+		nav = append(nav, tgbotapi.NewKeyboardButton(wizardBack))
+	}
+	if canSkip {
+		nav = append(nav, tgbotapi.NewKeyboardButton(wizardSkip))
+	}
+	nav = append(nav, tgbotapi.NewKeyboardButton(wizardCancel))
+	rows = append(rows, nav)
+
+	return tgbotapi.NewReplyKeyboard(rows...)
+}
+
+// handleWizardMessage feeds message to the wizard the user is in.
+func handleWizardMessage(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, state UserState) {
+	key := stateKey(message)
+	w, ok := wizards[state.Wizard]
+	if !ok || !w.hasStep(state.Step) {
+		log.Printf("Dropping unknown wizard state %q step %d of %s", state.Wizard, state.Step, key)
+		clearState(key)
+		return
+	}
+	if state.Answers == nil {
+		state.Answers = map[string]string{}
+	}
+
+	switch state.Step {
+	case w.reviewStep():
+		handleWizardReview(bot, store, message, w, state)
+		return
+	case w.pickStep():
+		handleWizardPick(bot, message, w, state)
+		return
+	}
+	step := w.Steps[state.Step]
+
+	switch message.Text {
+	case wizardCancel:
+		cancelWizard(bot, message)
+		return
+	case wizardBack:
+		switch {
+		case state.Reviewing:
+			state.moveTo(w.reviewStep())
+		case w.prevStep(state.Step, state.Answers) >= 0:
+			state.moveTo(w.prevStep(state.Step, state.Answers))
+		}
+		askStep(bot, message, w, state)
+		return
+	case wizardSkip:
+		// Skipping keeps the answer given earlier, if there is one.
+		if _, answered := state.Answers[step.Name]; !answered && !step.Optional {
+			sendPrompt(bot, message, state, replyTo(message, "This step can't be skipped."))
+			return
+		}
+	case wizardDone:
+		// Done ends a step that takes several messages. Anywhere else it
+		// would move on without an answer.
+		if !step.Multi {
+			sendPrompt(bot, message, state, replyTo(message, "Press "+wizardDone+" only while sending lyrics."))
+			return
+		}
+		if _, answered := state.Answers[step.Name]; !answered && !step.Optional {
+			sendPrompt(bot, message, state, replyTo(message, "Please send at least one message first."))
+			return
+		}
+	default:
+		previous, answered := state.Answers[step.Name]
+		if step.Multi && state.Collected == 0 {
+			delete(state.Answers, step.Name)
+		}
+		if err := step.Accept(bot, store, message, state.Answers); err != nil {
+			if step.Multi && state.Collected == 0 && answered {
+				state.Answers[step.Name] = previous
+			}
+			sendPrompt(bot, message, state, replyTo(message, err.Error()))
+			return
+		}
+		if step.Multi {
+			state.Collected++
+			if step.Progress == nil {
+				setState(stateKey(message), state)
+				return
+			}
+			sendPrompt(bot, message, state, replyTo(message, step.Progress(state.Answers)))
+			return
+		}
+	}
+
+	state.moveTo(w.nextStep(state.Step, state.Answers))
+	if state.Reviewing || (state.Step == len(w.Steps) && w.Review != nil) {
+		state.moveTo(w.reviewStep())
+		state.Reviewing = true
+	}
+	if state.Step < len(w.Steps) || state.Reviewing {
+		askStep(bot, message, w, state)
+		return
+	}
+	finishWizard(bot, store, message, w, state)
+}
+
+// handleWizardReview acts on the review screen's buttons.
+func handleWizardReview(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, w *Wizard, state UserState) {
+	switch message.Text {
+	case wizardSave:
+		finishWizard(bot, store, message, w, state)
+		return
+	case wizardDiscard, wizardCancel:
+		cancelWizard(bot, message)
+		return
+	case wizardEditField:
+		state.moveTo(w.pickStep())
+	case wizardBack:
+		state.moveTo(w.prevStep(len(w.Steps), state.Answers))
+		state.Reviewing = false
+	}
+	askStep(bot, message, w, state)
+}
+
+// handleWizardPick goes to the step whose label the user chose.
+func handleWizardPick(bot *tgbotapi.BotAPI, message *tgbotapi.Message, w *Wizard, state UserState) {
+	switch message.Text {
+	case wizardCancel:
+		cancelWizard(bot, message)
+		return
+	case wizardBack:
+		state.moveTo(w.reviewStep())
+	default:
+		for i, step := range w.Steps {
+			if message.Text == step.Label && step.applies(state.Answers) {
+				state.moveTo(i)
+			}
+		}
+	}
+	askStep(bot, message, w, state)
+}
+
+// finishWizard ends the conversation and hands the answers to Done.
+func finishWizard(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, w *Wizard, state UserState) {
+	clearState(stateKey(message))
+	w.Done(bot, store, message.Chat.ID, state.Answers)
+	sendMainMenu(bot, message.Chat.ID)
+}
+
+// cancelWizard abandons whatever the sender of message was in the middle of
+// in its chat.
+func cancelWizard(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	if state, ok := getState(stateKey(message)); ok {
+		if w, ok := wizards[state.Wizard]; ok && w.Cancel != nil {
+			w.Cancel(state.Answers)
+		}
+	}
+	clearState(stateKey(message))
+	msg := replyTo(message, "Cancelled.")
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	bot.Send(msg)
+	sendMainMenu(bot, message.Chat.ID)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// acceptText records a non-empty text answer under name.
+func acceptText(name, retry string) func(*tgbotapi.BotAPI, SongStore, *tgbotapi.Message, map[string]string) error {
+	return func(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+		text := strings.TrimSpace(message.Text)
+		if text == "" {
+			return errors.New(retry)
+		}
+		answers[name] = text
+		return nil
+	}
+}
+
+// acceptChoice records text that is one of choices under name.
+func acceptChoice(name, retry string, choices ...string) func(*tgbotapi.BotAPI, SongStore, *tgbotapi.Message, map[string]string) error {
+	return func(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+		for _, choice := range choices {
+			if message.Text == choice {
+				answers[name] = choice
+				return nil
+			}
+		}
+		return errors.New(retry)
+	}
+}
+
+// acceptImage keeps an uploaded photo in the image store, or takes the text
+// as an image URL, recording the reference under "image". An image uploaded
+// for an earlier answer is deleted from the store.
+func acceptImage(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+	previous := answers["image"]
+	if message.Photo == nil {
+		if err := acceptText("image", "Please send an image or its URL.")(bot, store, message, answers); err != nil {
+			return err
+		}
+		// A URL has no file_id; keeping the old photo's would show that instead.
+		delete(answers, "image_file_id")
+	} else {
+		// Get the highest resolution photo
+		photo := (*message.Photo)[len(*message.Photo)-1]
+		ref, err := images.Put(context.TODO(), telegramImage(bot, photo))
+		if err != nil {
+			log.Printf("Failed to store image: %v", err)
+			return errors.New(imageErrorText(err))
+		}
+		answers["image"] = ref
+		answers["image_file_id"] = photo.FileID
+	}
+	if previous != "" && previous != answers["image"] {
+		deleteImage(previous)
+	}
+	return nil
+}
+
+// deleteImage removes ref from the image store, logging failures. Stores
+// ignore references they did not create, such as plain URLs.
+func deleteImage(ref string) {
+	if err := images.Delete(context.TODO(), ref); err != nil {
+		log.Printf("Failed to delete image %s: %v", ref, err)
+	}
+}
+
+// maxLyricsFileBytes bounds a .txt file sent as lyrics.
+const maxLyricsFileBytes = 256 << 10
+
+// acceptLyrics appends the text of message, or of a .txt document, to the
+// lyrics collected so far. Telegram splits long pastes wherever the limit
+// falls, often mid-line, so pieces are joined by a line break. A blank line
+// at the start of a piece or the end of the one before still separates
+// stanzas; the latter is kept as trailing "\n\n" until the next piece.
+func acceptLyrics(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+	raw := message.Text
+	if message.Document != nil {
+		var err error
+		if raw, err = readTextDocument(bot, message.Document); err != nil {
+			return err
+		}
+	}
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	text := strings.TrimSpace(raw)
+	if text == "" {
+		return errors.New("Please send the lyrics as text or a .txt file:")
+	}
+	leading := raw[:len(raw)-len(strings.TrimLeftFunc(raw, unicode.IsSpace))]
+	trailing := raw[len(strings.TrimRightFunc(raw, unicode.IsSpace)):]
+
+	if lyrics := answers["lyrics"]; lyrics != "" {
+		if strings.HasSuffix(lyrics, "\n\n") || strings.Contains(leading, "\n") {
+			text = strings.TrimRight(lyrics, "\n") + "\n\n" + text
+		} else {
+			text = lyrics + "\n" + text
+		}
+	}
+	if strings.Count(trailing, "\n") > 1 {
+		text += "\n\n"
+	}
+	answers["lyrics"] = text
+	return nil
+}
+
+// lyricsProgress reports how much of the lyrics has been received.
+func lyricsProgress(answers map[string]string) string {
+	lyrics := strings.TrimSpace(answers["lyrics"])
+	return fmt.Sprintf("Got it: %d characters in %d stanzas so far. Send more, or press Done.",
+		utf8.RuneCountInString(lyrics), len(stanzaBreak.Split(lyrics, -1)))
+}
+
+// readTextDocument downloads a plain-text document sent to the bot. Its
+// errors are meant for the user.
+func readTextDocument(bot *tgbotapi.BotAPI, doc *tgbotapi.Document) (string, error) {
+	if doc.MimeType != "text/plain" && !strings.HasSuffix(strings.ToLower(doc.FileName), ".txt") {
+		return "", errors.New("Please send the lyrics as text or a .txt file:")
+	}
+	tooLarge := fmt.Errorf("That file is too large; lyrics files can be up to %d KB.", maxLyricsFileBytes>>10)
+	if doc.FileSize > maxLyricsFileBytes {
+		return "", tooLarge
+	}
+
+	fileURL, err := bot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		log.Printf("Failed to get lyrics file: %v", err)
+		return "", errors.New("Failed to download the file. Please try again:")
+	}
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		log.Printf("Failed to download lyrics file: %v", err)
+		return "", errors.New("Failed to download the file. Please try again:")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to download lyrics file: status %d", resp.StatusCode)
+		return "", errors.New("Failed to download the file. Please try again:")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxLyricsFileBytes+1))
+	if err != nil {
+		log.Printf("Failed to read lyrics file: %v", err)
+		return "", errors.New("Failed to download the file. Please try again:")
+	}
+	if len(data) > maxLyricsFileBytes {
+		return "", tooLarge
+	}
+	if !utf8.Valid(data) {
+		return "", errors.New("That file is not UTF-8 text. Please save it as UTF-8 and send it again:")
+	}
+	return strings.TrimPrefix(string(data), "\ufeff"), nil
+}
+
+// songFromAnswers builds the song described by the add wizard's answers.
+func songFromAnswers(answers map[string]string) *Song {
+	return &Song{
+		Title:       answers["title"],
+		Lyrics:      strings.TrimSpace(answers["lyrics"]),
+		Image:       answers["image"],
+		ImageFileID: answers["image_file_id"],
+		Category:    answers["category"],
+	}
+}
+
+// addSongWizard asks for a new song's title, category, lyrics and image,
+// then shows the song for review before saving it.
+var addSongWizard = &Wizard{
+	Name: "add_song",
+	Steps: []WizardStep{
+		{
+			Name:   "title",
+			Label:  "Title",
+			Prompt: prompt("Please enter the song title:"),
+			Accept: acceptText("title", "Please enter the song title as text:"),
+		},
+		{
+			Name:    "category",
+			Label:   "Category",
+			Prompt:  prompt("Please select the song category:"),
+			Buttons: []string{"Choir", "Non-Choir"},
+			Accept:  acceptChoice("category", "Please select a valid category (Choir/Non-Choir):", "Choir", "Non-Choir"),
+		},
+		{
+			Name:     "lyrics",
+			Label:    "Lyrics",
+			Prompt:   prompt("Great! Now please enter the lyrics. Long lyrics can be sent in several messages or as a .txt file; press Done when they are complete."),
+			Multi:    true,
+			Accept:   acceptLyrics,
+			Progress: lyricsProgress,
+		},
+		{
+			Name:     "image",
+			Label:    "Image",
+			Prompt:   prompt("Perfect! Now please send the image URL or upload an image, or press Skip to show the song on a lyric card:"),
+			Optional: true,
+			Accept:   acceptImage,
+		},
+	},
+	Review: func(answers map[string]string) string {
+		image := "none, a lyric card will be shown"
+		if answers["image"] != "" {
+			image = "added"
+		}
+		return fmt.Sprintf("Please review the song before saving:\n\n%s\n\n<i>Image: %s</i>",
+			renderSongHTML(songFromAnswers(answers)), image)
+	},
+	Done: func(bot *tgbotapi.BotAPI, store SongStore, chatID int64, answers map[string]string) {
+		err := store.Insert(context.TODO(), songFromAnswers(answers))
+		if err != nil {
+			log.Printf("Failed to insert song: %v", err)
+			bot.Send(tgbotapi.NewMessage(chatID, "Failed to add song."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Song added successfully!"))
+	},
+	Cancel: func(answers map[string]string) {
+		if ref := answers["image"]; ref != "" {
+			deleteImage(ref)
+		}
+	},
+}
+
+// editFields maps the edit wizard's buttons to song fields.
+var editFields = map[string]string{
+	"Edit Title":    "title",
+	"Edit Lyrics":   "lyrics",
+	"Edit Category": "category",
+	"Edit Image":    "image",
+	"Edit Composer": "composer",
+	"Edit Key":      "key",
+}
+
+// editSongWizard picks a song and one of its fields and sets a new value.
+var editSongWizard = &Wizard{
+	Name: "edit_song",
+	Steps: []WizardStep{
+		{
+			Name:   "song",
+			Label:  "Song",
+			Prompt: prompt("Please enter the title of the song you want to edit:"),
+			Accept: func(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+				song, exists := findSong(store, message.Text)
+				if !exists {
+					return errors.New("Song not found. Please try again:")
+				}
+				// The ID still finds the song if it is renamed meanwhile.
+				answers["song"] = song.ID
+				return nil
+			},
+		},
+		{
+			Name:   "field",
+			Label:  "Field",
+			Prompt: prompt("What would you like to edit?"),
+			Buttons: []string{
+				"Edit Title", "Edit Lyrics",
+				"Edit Category", "Edit Image",
+				"Edit Composer", "Edit Key",
+			},
+			Accept: func(bot *tgbotapi.BotAPI, store SongStore, message *tgbotapi.Message, answers map[string]string) error {
+				field, ok := editFields[message.Text]
+				if !ok {
+					return errors.New("Please choose what to edit from the buttons.")
+				}
+				answers["field"] = field
+				return nil
+			},
+		},
+		{
+			Name:  "value",
+			Label: "Value",
+			Prompt: func(answers map[string]string) string {
+				return fmt.Sprintf("Please enter the new %s:", answers["field"])
+			},
+			When:   func(answers map[string]string) bool { return answers["field"] != "lyrics" },
+			Accept: acceptText("value", "Please enter the new value as text:"),
+		},
+		{
+			Name:     "lyrics",
+			Label:    "Lyrics",
+			Prompt:   prompt("Please enter the new lyrics. Long lyrics can be sent in several messages or as a .txt file; press Done when they are complete."),
+			When:     func(answers map[string]string) bool { return answers["field"] == "lyrics" },
+			Multi:    true,
+			Accept:   acceptLyrics,
+			Progress: lyricsProgress,
+		},
+	},
+	Done: func(bot *tgbotapi.BotAPI, store SongStore, chatID int64, answers map[string]string) {
+		value := answers["value"]
+		if answers["field"] == "lyrics" {
+			value = strings.TrimSpace(answers["lyrics"])
+		}
+		// Update the stored song
+		err := updateSongField(store, answers["song"], answers["field"], value)
+		if err != nil {
+			log.Printf("Failed to update song %q: %v", answers["song"], err)
+			bot.Send(tgbotapi.NewMessage(chatID, "Failed to update the song."))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, "Song updated successfully!"))
+	},
+}
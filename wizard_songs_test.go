@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestAcceptLyricsJoinsPieces(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   string
+	}{
+		{"one piece", []string{"Line one\nLine two"}, "Line one\nLine two"},
+		{"split mid-line", []string{"Amazing grace, how", "sweet the sound"}, "Amazing grace, how\nsweet the sound"},
+		{"split mid-stanza", []string{"a\nb\n\nc", "d\n\ne"}, "a\nb\n\nc\nd\n\ne"},
+		{"blank line starting a piece", []string{"a\nb", "\n\nc"}, "a\nb\n\nc"},
+		{"blank line ending a piece", []string{"a\nb\n\n", "c"}, "a\nb\n\nc"},
+		{"windows line ends", []string{"a\r\nb", "c\r\n\r\n"}, "a\nb\nc\n\n"},
+	}
+	for _, tt := range tests {
+		answers := map[string]string{}
+		for _, piece := range tt.pieces {
+			if err := acceptLyrics(nil, nil, &tgbotapi.Message{Text: piece}, answers); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		if got := answers["lyrics"]; got != tt.want {
+			t.Errorf("%s: lyrics = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptLyricsRejectsBlank(t *testing.T) {
+	answers := map[string]string{"lyrics": "a"}
+	if err := acceptLyrics(nil, nil, &tgbotapi.Message{Text: " \n "}, answers); err == nil {
+		t.Errorf("blank piece accepted")
+	}
+	if answers["lyrics"] != "a" {
+		t.Errorf("blank piece changed the lyrics to %q", answers["lyrics"])
+	}
+}
+
+func TestLyricsProgressCountsStanzas(t *testing.T) {
+	answers := map[string]string{}
+	for _, piece := range []string{"Amazing grace, how", "sweet the sound\n\nThat saved a wretch", "like me\n\n"} {
+		if err := acceptLyrics(nil, nil, &tgbotapi.Message{Text: piece}, answers); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := lyricsProgress(answers); !strings.Contains(got, "in 2 stanzas") {
+		t.Errorf("lyricsProgress = %q, want 2 stanzas", got)
+	}
+	if got := songFromAnswers(answers).Lyrics; strings.HasSuffix(got, "\n") {
+		t.Errorf("saved lyrics %q keep the trailing break", got)
+	}
+}
+
+func TestEditSongWizardCollectsLyrics(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	song := &Song{Title: "Amazing Grace", Lyrics: "old", Key: "G"}
+	if err := h.store.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "✏️ Edit Song"), editSongWizard)
+	for _, input := range []string{"Amazing Grace", "Edit Lyrics", "Amazing grace, how", "sweet the sound", wizardDone} {
+		h.run(input)
+	}
+
+	got, _ := h.store.Get(context.Background(), song.ID)
+	if got.Lyrics != "Amazing grace, how\nsweet the sound" || got.Key != "G" {
+		t.Errorf("edited song = %+v, want both pieces as the lyrics", got)
+	}
+	if _, ok := h.state(int64(adminID)); ok {
+		t.Errorf("wizard still running after Done")
+	}
+}
+
+func TestEditSongWizardBackSkipsLyricsStep(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	if err := h.store.Insert(context.Background(), &Song{Title: "Amazing Grace"}); err != nil {
+		t.Fatal(err)
+	}
+
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "✏️ Edit Song"), editSongWizard)
+	for _, input := range []string{"Amazing Grace", "Edit Lyrics", wizardBack} {
+		h.run(input)
+	}
+	if state, _ := h.state(int64(adminID)); state.Step != 1 {
+		t.Errorf("Back from the lyrics step went to step %d, want the field step", state.Step)
+	}
+	h.run("Edit Key")
+	if state, _ := h.state(int64(adminID)); state.Step != 2 {
+		t.Errorf("choosing Key went to step %d, want the value step", state.Step)
+	}
+}
+
+func TestEditSongWizardDoneKeepsValue(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	song := &Song{Title: "Amazing Grace", Lyrics: "Amazing grace"}
+	if err := h.store.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "✏️ Edit Song"), editSongWizard)
+	for _, input := range []string{"Amazing Grace", "Edit Title", wizardDone} {
+		h.run(input)
+	}
+	if got, _ := h.store.Get(context.Background(), song.ID); got.Title != "Amazing Grace" {
+		t.Errorf("Done at the value step changed the title to %q", got.Title)
+	}
+	if state, _ := h.state(int64(adminID)); state.Step != 2 {
+		t.Errorf("Done at the value step went to step %d, want it asked again", state.Step)
+	}
+}
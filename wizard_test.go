@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// adminID is an admin's user ID, which is also their private chat's ID.
+var adminID = adminIDs[0]
+
+// wizardHarness drives wizards for one admin in their private chat, with a
+// fresh state store and song store.
+type wizardHarness struct {
+	bot   *tgbotapi.BotAPI
+	fake  *fakeTelegram
+	store SongStore
+	run   func(text string)
+}
+
+func newWizardHarness(t *testing.T, chatID int64) *wizardHarness {
+	t.Helper()
+	userStates = NewMemoryStateStore(defaultStateTTL)
+	images = TelegramImageStore{}
+	bot, fake := newFakeBot(t)
+	h := &wizardHarness{bot: bot, fake: fake, store: NewMemorySongStore()}
+	h.run = func(text string) {
+		message := textMessage(chatID, adminID, text)
+		state, ok := getState(stateKey(message))
+		if !ok {
+			t.Fatalf("no conversation to send %q to", text)
+		}
+		handleWizardMessage(h.bot, h.store, message, state)
+	}
+	return h
+}
+
+// state returns the admin's state, and false once the wizard has ended.
+func (h *wizardHarness) state(chatID int64) (UserState, bool) {
+	return getState(StateKey{ChatID: chatID, UserID: adminID})
+}
+
+func TestWizardNavigation(t *testing.T) {
+	add := func(extra ...string) []string {
+		return append([]string{"Amazing Grace", "Choir", "Amazing grace, how sweet the sound", wizardDone}, extra...)
+	}
+	tests := []struct {
+		name        string
+		wizard      *Wizard
+		inputs      []string
+		wantStep    int // -1 once the wizard has finished
+		wantAnswers map[string]string
+		wantReply   string
+	}{
+		{
+			name:        "first answer",
+			wizard:      addSongWizard,
+			inputs:      []string{"Amazing Grace"},
+			wantStep:    1,
+			wantAnswers: map[string]string{"title": "Amazing Grace"},
+			wantReply:   "category",
+		},
+		{
+			name:        "back keeps the answer",
+			wizard:      addSongWizard,
+			inputs:      []string{"Amazing Grace", wizardBack},
+			wantStep:    0,
+			wantAnswers: map[string]string{"title": "Amazing Grace"},
+			wantReply:   "title",
+		},
+		{
+			name:        "skip keeps the earlier answer",
+			wizard:      addSongWizard,
+			inputs:      []string{"Amazing Grace", wizardBack, wizardSkip},
+			wantStep:    1,
+			wantAnswers: map[string]string{"title": "Amazing Grace"},
+		},
+		{
+			name:        "unanswered required step cannot be skipped",
+			wizard:      addSongWizard,
+			inputs:      []string{wizardSkip},
+			wantStep:    0,
+			wantAnswers: map[string]string{},
+			wantReply:   "can't be skipped",
+		},
+		{
+			name:        "done outside the lyrics step is refused",
+			wizard:      addSongWizard,
+			inputs:      []string{wizardDone, "Amazing Grace", wizardDone},
+			wantStep:    1,
+			wantAnswers: map[string]string{"title": "Amazing Grace"},
+			wantReply:   "only while sending lyrics",
+		},
+		{
+			name:        "invalid choice is asked again",
+			wizard:      addSongWizard,
+			inputs:      []string{"Amazing Grace", "Hymns"},
+			wantStep:    1,
+			wantAnswers: map[string]string{"title": "Amazing Grace"},
+			wantReply:   "valid category",
+		},
+		{
+			name:     "optional image skipped to review",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip),
+			wantStep: addSongWizard.reviewStep(),
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+			wantReply: "Save, change a field, or discard?",
+		},
+		{
+			name:     "edit field from review returns to review",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip, wizardEditField, "Title", "Amazing Grace (Hymn)"),
+			wantStep: addSongWizard.reviewStep(),
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace (Hymn)", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+			wantReply: "Save, change a field, or discard?",
+		},
+		{
+			name:     "back from pick returns to review",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip, wizardEditField, wizardBack),
+			wantStep: addSongWizard.reviewStep(),
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+		},
+		{
+			name:     "back from review goes to the last step",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip, wizardBack),
+			wantStep: 3,
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+		},
+		{
+			name:     "back keeps walking back after leaving review",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip, wizardBack, wizardBack, wizardBack),
+			wantStep: 1,
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+		},
+		{
+			name:     "answering after leaving review goes on to review",
+			wizard:   addSongWizard,
+			inputs:   add(wizardSkip, wizardBack, wizardBack, wizardBack, "Choir", wizardDone, wizardSkip),
+			wantStep: addSongWizard.reviewStep(),
+			wantAnswers: map[string]string{
+				"title": "Amazing Grace", "category": "Choir", "lyrics": "Amazing grace, how sweet the sound",
+			},
+		},
+		{
+			name:      "save",
+			wizard:    addSongWizard,
+			inputs:    add("https://example.com/grace.jpg", wizardSave),
+			wantStep:  -1,
+			wantReply: "Song added successfully!",
+		},
+		{
+			name:      "discard",
+			wizard:    addSongWizard,
+			inputs:    add(wizardSkip, wizardDiscard),
+			wantStep:  -1,
+			wantReply: "Cancelled.",
+		},
+		{
+			name:      "cancel midway",
+			wizard:    addSongWizard,
+			inputs:    []string{"Amazing Grace", wizardCancel},
+			wantStep:  -1,
+			wantReply: "Cancelled.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newWizardHarness(t, int64(adminID))
+			startWizard(h.bot, textMessage(int64(adminID), adminID, "➕ Add Song"), tt.wizard)
+			var replies []string
+			for _, input := range tt.inputs {
+				h.run(input)
+				replies = append(replies, h.fake.texts()...)
+			}
+
+			state, ok := h.state(int64(adminID))
+			if tt.wantStep < 0 {
+				if ok {
+					t.Errorf("wizard still running at step %d", state.Step)
+				}
+			} else {
+				if !ok {
+					t.Fatalf("wizard ended, want step %d", tt.wantStep)
+				}
+				if state.Step != tt.wantStep {
+					t.Errorf("step = %d, want %d", state.Step, tt.wantStep)
+				}
+				if !reflect.DeepEqual(state.Answers, tt.wantAnswers) {
+					t.Errorf("answers = %v, want %v", state.Answers, tt.wantAnswers)
+				}
+			}
+			if tt.wantReply != "" && !containsReply(replies, tt.wantReply) {
+				t.Errorf("replies %q do not mention %q", replies, tt.wantReply)
+			}
+		})
+	}
+}
+
+func containsReply(replies []string, want string) bool {
+	for _, reply := range replies {
+		if strings.Contains(reply, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAddSongWizardSaves(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "➕ Add Song"), addSongWizard)
+	for _, input := range []string{"Amazing Grace", "Choir", "Amazing grace", wizardDone, wizardSkip, wizardSave} {
+		h.run(input)
+	}
+
+	song, err := h.store.FindByTitle(context.Background(), "Amazing Grace")
+	if err != nil {
+		t.Fatalf("song not saved: %v", err)
+	}
+	if song.Category != "Choir" || song.Lyrics != "Amazing grace" || song.Image != "" {
+		t.Errorf("saved %+v", song)
+	}
+}
+
+func TestEditSongWizardFollowsRename(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	song := &Song{Title: "Amazing Grace", Lyrics: "Amazing grace"}
+	if err := h.store.Insert(context.Background(), song); err != nil {
+		t.Fatal(err)
+	}
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "✏️ Edit Song"), editSongWizard)
+	h.run("Amazing Grace")
+	h.run("Edit Key")
+
+	// Another admin renames the song while this one is typing.
+	renamed := *song
+	renamed.Title = "Amazing Grace (Hymn)"
+	if err := h.store.Update(context.Background(), &renamed); err != nil {
+		t.Fatal(err)
+	}
+	// ...and adds a new song under its old title.
+	twin := &Song{Title: "Amazing Grace", Lyrics: "Amazing grace"}
+	if err := h.store.Insert(context.Background(), twin); err != nil {
+		t.Fatal(err)
+	}
+	h.run("G")
+
+	got, _ := h.store.Get(context.Background(), song.ID)
+	if got.Key != "G" || got.Title != "Amazing Grace (Hymn)" {
+		t.Errorf("edited song = %+v, want the renamed song in G", got)
+	}
+	if other, _ := h.store.Get(context.Background(), twin.ID); other.Key != "" {
+		t.Errorf("edit landed on another song: %+v", other)
+	}
+}
+
+func mustState(t *testing.T, key StateKey) UserState {
+	t.Helper()
+	state, ok := getState(key)
+	if !ok {
+		t.Fatalf("no state for %s", key)
+	}
+	return state
+}
+
+// recordingImageStore hands out numbered references and records deletions.
+type recordingImageStore struct {
+	puts    int
+	deleted []string
+}
+
+func (s *recordingImageStore) Put(ctx context.Context, img Image) (string, error) {
+	s.puts++
+	return fmt.Sprintf("local:%d.jpg", s.puts), nil
+}
+
+func (s *recordingImageStore) URL(ref string) string { return ref }
+
+func (s *recordingImageStore) Delete(ctx context.Context, ref string) error {
+	s.deleted = append(s.deleted, ref)
+	return nil
+}
+
+func photoMessage(chatID int64, userID int, fileID string) *tgbotapi.Message {
+	message := textMessage(chatID, userID, "")
+	message.Photo = &[]tgbotapi.PhotoSize{{FileID: fileID}}
+	return message
+}
+
+func TestWizardImageReplacedByURL(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	stored := &recordingImageStore{}
+	images = stored
+	key := StateKey{ChatID: int64(adminID), UserID: adminID}
+
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "➕ Add Song"), addSongWizard)
+	for _, input := range []string{"Amazing Grace", "Choir", "Amazing grace", wizardDone} {
+		h.run(input)
+	}
+	handleWizardMessage(h.bot, h.store, photoMessage(int64(adminID), adminID, "photo-1"), mustState(t, key))
+	if got := mustState(t, key).Answers["image_file_id"]; got != "photo-1" {
+		t.Fatalf("image_file_id = %q after the upload, want photo-1", got)
+	}
+
+	for _, input := range []string{wizardEditField, "Image", "https://example.com/grace.jpg"} {
+		h.run(input)
+	}
+	answers := mustState(t, key).Answers
+	if _, ok := answers["image_file_id"]; ok || answers["image"] != "https://example.com/grace.jpg" {
+		t.Errorf("answers = %v, want only the URL", answers)
+	}
+	if !reflect.DeepEqual(stored.deleted, []string{"local:1.jpg"}) {
+		t.Errorf("deleted = %v, want the replaced upload", stored.deleted)
+	}
+
+	h.run(wizardSave)
+	song, err := h.store.FindByTitle(context.Background(), "Amazing Grace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if song.Image != "https://example.com/grace.jpg" || song.ImageFileID != "" {
+		t.Errorf("saved image %q with file_id %q, want the URL alone", song.Image, song.ImageFileID)
+	}
+}
+
+func TestWizardDiscardDeletesUpload(t *testing.T) {
+	h := newWizardHarness(t, int64(adminID))
+	stored := &recordingImageStore{}
+	images = stored
+	key := StateKey{ChatID: int64(adminID), UserID: adminID}
+
+	startWizard(h.bot, textMessage(int64(adminID), adminID, "➕ Add Song"), addSongWizard)
+	for _, input := range []string{"Amazing Grace", "Choir", "Amazing grace", wizardDone} {
+		h.run(input)
+	}
+	handleWizardMessage(h.bot, h.store, photoMessage(int64(adminID), adminID, "photo-1"), mustState(t, key))
+	h.run(wizardDiscard)
+
+	if !reflect.DeepEqual(stored.deleted, []string{"local:1.jpg"}) {
+		t.Errorf("deleted = %v, want the discarded upload", stored.deleted)
+	}
+	if songs, _ := h.store.All(context.Background()); len(songs) != 0 {
+		t.Errorf("discarded song was saved: %+v", songs)
+	}
+}